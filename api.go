@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"gopkg.in/yaml.v3"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+const (
+	modulesEndpoint = "/api/v1/modules"
+
+	// maxModuleSize limits the size of module definitions
+	// submitted to the module API
+	maxModuleSize = 1 << 20
+)
+
+// modulesHandlerFunc lists the modules or manages the module identified
+// by the path suffix of the request. Module definitions are accepted
+// as YAML or JSON and checked like the configuration file. Changes
+// are only accepted if manage is set.
+func modulesHandlerFunc(manage bool, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, modulesEndpoint), "/")
+		if strings.Contains(name, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if name == "" {
+			if r.Method != "GET" {
+				w.Header().Set("Allow", "GET")
+				http.Error(w, "GET method expected", http.StatusMethodNotAllowed)
+				return
+			}
+
+			writeJSON(w, sc.Snapshot().Config.Catalog(), logger)
+			return
+		}
+
+		if r.Method != "GET" && !manage {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Module changes are disabled; see --web.enable-module-api", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var result *config.Reload
+		var err error
+
+		switch r.Method {
+		case "GET":
+			module, ok := sc.Snapshot().Config.Modules[name]
+			if !ok {
+				http.Error(w, fmt.Sprintf("Unknown module %q", name), http.StatusNotFound)
+				return
+			}
+
+			writeJSON(w, module.Info(name), logger)
+			return
+		case "PUT":
+			var module config.Module
+			data, readErr := io.ReadAll(http.MaxBytesReader(w, r.Body, maxModuleSize))
+			if readErr != nil {
+				http.Error(w, fmt.Sprintf("Unable to read module definition: %s", readErr), http.StatusBadRequest)
+				return
+			}
+
+			// JSON documents are valid YAML as well
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(&module); errors.Is(err, io.EOF) {
+				http.Error(w, "Module definition is missing", http.StatusBadRequest)
+				return
+			} else if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid module definition: %s", err), http.StatusBadRequest)
+				return
+			}
+
+			result, err = sc.PutModule(name, module, logger)
+		case "DELETE":
+			result, err = sc.DeleteModule(name, logger)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, "GET, PUT or DELETE method expected", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if errors.Is(err, config.ErrInvalidModule) {
+			level.Info(logger).Log("msg", "Rejected module change", "module", name, "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if errors.Is(err, config.ErrUnknownModule) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			level.Error(logger).Log("msg", "Error changing module", "module", name, "err", err)
+			http.Error(w, fmt.Sprintf("failed to change module: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		tc.Flush()
+		level.Info(logger).Log("msg", "Changed module", "module", name, "method", r.Method, "generation", result.Generation)
+
+		writeJSON(w, result, logger)
+	}
+}
+
+// writeJSON renders the given value as JSON response
+func writeJSON(w http.ResponseWriter, v interface{}, logger log.Logger) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		level.Error(logger).Log("msg", "Unable to render API response", "err", err)
+		http.Error(w, "Unable to render API response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -2,10 +2,12 @@ package config
 
 import (
 	"bytes"
-	"fmt"
 	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
 )
 
 const (
@@ -16,14 +18,14 @@ const (
 // Argument defines the condition and representation of a commandline
 // argument to a module command
 type Argument struct {
-	Condition BoolString `yaml:"set_if,omitempty"`
-	Value     LazyArray  `yaml:"value,omitempty"`
-	Order     int        `yaml:"order,omitempty"`
-	Key       string     `yaml:"key,omitempty"`
-	Required  BoolString `yaml:"required,omitempty"`
-	RepeatKey BoolString `yaml:"repeat_key,omitempty"`
-	SkipKey   BoolString `yaml:"skip_key,omitempty"`
-	Separator string     `yaml:"separator,omitempty"`
+	Condition BoolString `yaml:"set_if,omitempty" json:"set_if,omitempty"`
+	Value     LazyArray  `yaml:"value,omitempty" json:"value,omitempty"`
+	Order     int        `yaml:"order,omitempty" json:"order,omitempty"`
+	Key       string     `yaml:"key,omitempty" json:"key,omitempty"`
+	Required  BoolString `yaml:"required,omitempty" json:"required,omitempty"`
+	RepeatKey BoolString `yaml:"repeat_key,omitempty" json:"repeat_key,omitempty"`
+	SkipKey   BoolString `yaml:"skip_key,omitempty" json:"skip_key,omitempty"`
+	Separator string     `yaml:"separator,omitempty" json:"separator,omitempty"`
 }
 
 // UnmarshalYAML populates the instace fields from the
@@ -46,23 +48,72 @@ func (a *Argument) UnmarshalYAML(value *yaml.Node) error {
 	return value.Decode((*rawArgument)(a))
 }
 
+// Variables returns the names of the variables referenced
+// by the value and condition of the argument, sorted
+func (a Argument) Variables(macros bool) []string {
+	refs := newTemplateRefs(macros)
+	for _, s := range a.Value {
+		refs.add(s)
+	}
+	refs.add(string(a.Condition))
+
+	return refs.sortedVars()
+}
+
+// ConditionVariables returns the names of the variables
+// referenced by the condition of the argument, sorted
+func (a Argument) ConditionVariables(macros bool) []string {
+	refs := newTemplateRefs(macros)
+	refs.add(string(a.Condition))
+
+	return refs.sortedVars()
+}
+
+// literal escapes the dollar signs in all fields without template syntax,
+// which are passed along as is when runtime macros are not enabled
+func (a Argument) literal() Argument {
+	escape := func(s string) string {
+		if strings.Contains(s, template.TemplateToken) {
+			return s
+		}
+
+		return strings.ReplaceAll(s, "$", "$$")
+	}
+
+	if a.Value != nil {
+		value := make(LazyArray, len(a.Value))
+		for i, v := range a.Value {
+			value[i] = escape(v)
+		}
+
+		a.Value = value
+	}
+	a.Condition = BoolString(escape(string(a.Condition)))
+	a.Required = BoolString(escape(string(a.Required)))
+	a.RepeatKey = BoolString(escape(string(a.RepeatKey)))
+	a.SkipKey = BoolString(escape(string(a.SkipKey)))
+
+	return a
+}
+
 // MarshalIcinga renders the argument in the Icinga config syntax format
 func (a *Argument) MarshalIcinga(name string) ([]byte, error) {
 	buf := &bytes.Buffer{}
+	body := &bytes.Buffer{}
 
-	buf.WriteByte('"')
-	buf.WriteString(name)
-	buf.WriteString("\" = {\n")
+	buf.WriteString(icingaQuote(name))
+	buf.WriteString(" = {\n")
 
-	a.marshalIcingaCondition(buf)
-	a.marshalIcingaValue(buf)
-	a.marshalIcingaOrder(buf)
-	a.marshalIcingaKey(buf)
-	a.marshalIcingaRequired(buf)
-	a.marshalIcingaRepeatKey(buf)
-	a.marshalIcingaSkipKey(buf)
-	a.marshalIcingaSeparator(buf)
+	a.marshalIcingaCondition(body)
+	a.marshalIcingaValue(body)
+	a.marshalIcingaOrder(body)
+	a.marshalIcingaKey(body)
+	a.marshalIcingaBool(body, "required", a.Required, false)
+	a.marshalIcingaBool(body, "repeat_key", a.RepeatKey, true)
+	a.marshalIcingaBool(body, "skip_key", a.SkipKey, false)
+	a.marshalIcingaSeparator(body)
 
+	icingaIndent(buf, body.Bytes(), "  ")
 	buf.WriteString("}\n")
 
 	return buf.Bytes(), nil
@@ -70,18 +121,8 @@ func (a *Argument) MarshalIcinga(name string) ([]byte, error) {
 
 func (a *Argument) marshalIcingaCondition(buf *bytes.Buffer) {
 	if a.Condition != "" {
-		b, err := strconv.ParseBool(string(a.Condition))
-		if err != nil {
-			buf.WriteString(`  set_if = "`)
-			buf.WriteString(string(a.Condition))
-			buf.WriteByte('"')
-		} else if b {
-			buf.WriteString(`  set_if = true`)
-		} else {
-			buf.WriteString(`  set_if = false`)
-		}
-
-		buf.WriteByte('\n')
+		expr, comment := icingaBool(a.Condition)
+		icingaAssign(buf, "set_if", expr, comment)
 	}
 }
 
@@ -91,91 +132,55 @@ func (a *Argument) marshalIcingaValue(buf *bytes.Buffer) {
 	}
 
 	if len(a.Value) == 1 {
-		buf.WriteString(`  value = "`)
-		buf.WriteString(a.Value[0])
-		buf.WriteString("\"\n")
+		expr, comment := icingaValue(a.Value[0])
+		icingaAssign(buf, "value", expr, comment)
 
 		return
 	}
 
-	buf.WriteString(`  value = [\n`)
-	for _, v := range a.Value {
-		buf.WriteString(`    "`)
-		buf.WriteString(v)
-		buf.WriteString("\",\n")
+	items := make([]string, len(a.Value))
+	comments := []string{}
+	for i, v := range a.Value {
+		expr, comment := icingaValue(v)
+		if comment != "" {
+			comments = append(comments, comment)
+		}
+
+		items[i] = expr
 	}
-	buf.WriteString("  ]\n")
+
+	icingaAssign(buf, "value", "[ "+strings.Join(items, ", ")+" ]", strings.Join(comments, "; "))
 }
 
 func (a *Argument) marshalIcingaOrder(buf *bytes.Buffer) {
 	if a.Order != 0 {
-		fmt.Fprintf(buf, "  order = %d\n", a.Order)
+		icingaAssign(buf, "order", strconv.Itoa(a.Order), "")
 	}
 }
 
 func (a *Argument) marshalIcingaKey(buf *bytes.Buffer) {
 	if a.Key != "" {
-		buf.WriteString(`  key = "`)
-		buf.WriteString(string(a.Key))
-		buf.WriteString("\"\n")
+		icingaAssign(buf, "key", icingaQuote(a.Key), "")
 	}
 }
 
-func (a *Argument) marshalIcingaRequired(buf *bytes.Buffer) {
-	if a.Required != "" {
-		b, err := strconv.ParseBool(string(a.Required))
-		if err != nil {
-			buf.WriteString(`  required = "`)
-			buf.WriteString(string(a.Required))
-			buf.WriteByte('"')
-		} else if b {
-			buf.WriteString(`  required = true`)
-		} else {
-			buf.WriteString(`  required = false`)
-		}
-
-		buf.WriteByte('\n')
+// marshalIcingaBool omits values matching the Icinga default
+func (a *Argument) marshalIcingaBool(buf *bytes.Buffer, name string, b BoolString, def bool) {
+	if b == "" {
+		return
 	}
-}
-
-func (a *Argument) marshalIcingaRepeatKey(buf *bytes.Buffer) {
-	if a.RepeatKey != "" {
-		b, err := strconv.ParseBool(string(a.RepeatKey))
-		if err != nil {
-			buf.WriteString(`  repeat_key = "`)
-			buf.WriteString(string(a.RepeatKey))
-			buf.WriteByte('"')
-		} else if b {
-			buf.WriteString(`  repeat_key = true`)
-		} else {
-			buf.WriteString(`  repeat_key = false`)
-		}
 
-		buf.WriteByte('\n')
+	if v, err := strconv.ParseBool(string(b)); err == nil && v == def {
+		return
 	}
-}
-
-func (a *Argument) marshalIcingaSkipKey(buf *bytes.Buffer) {
-	if a.SkipKey != "" {
-		b, err := strconv.ParseBool(string(a.SkipKey))
-		if err != nil {
-			buf.WriteString(`  skip_key = "`)
-			buf.WriteString(string(a.SkipKey))
-			buf.WriteByte('"')
-		} else if b {
-			buf.WriteString(`  skip_key = true`)
-		} else {
-			buf.WriteString(`  skip_key = false`)
-		}
 
-		buf.WriteByte('\n')
-	}
+	expr, comment := icingaBool(b)
+	icingaAssign(buf, name, expr, comment)
 }
 
 func (a *Argument) marshalIcingaSeparator(buf *bytes.Buffer) {
-	if a.Separator != "" {
-		buf.WriteString(`  separator = "`)
-		buf.WriteString(string(a.Separator))
-		buf.WriteString("\"\n")
+	// Icinga passes key and value individually by default
+	if a.Separator != "" && a.Separator != " " {
+		icingaAssign(buf, "separator", icingaQuote(a.Separator), "")
 	}
 }
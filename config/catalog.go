@@ -0,0 +1,55 @@
+package config
+
+// ModuleInfo summarizes a module for clients building probe requests
+type ModuleInfo struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type,omitempty"`
+	Command string         `json:"command"`
+	Timeout NumberDuration `json:"timeout,omitempty"`
+	// Variables maps the declared variables, which are accepted
+	// as query parameters, to their default values. Variables
+	// which are not overridable are omitted.
+	Variables map[string][]string `json:"variables"`
+	// Arguments lists the argument keys passed to the command
+	Arguments []string `json:"arguments"`
+}
+
+// Info describes the given module. Defaults of secret
+// and credential-like variables are redacted.
+func (m *Module) Info(name string) *ModuleInfo {
+	result := &ModuleInfo{
+		Name:      name,
+		Type:      m.Type,
+		Command:   m.Command,
+		Timeout:   m.Timeout,
+		Variables: make(map[string][]string, len(m.Variables)),
+		Arguments: make([]string, 0, len(m.Arguments)),
+	}
+
+	for k, v := range m.Redacted().Variables {
+		if v.IsOverridable() {
+			result.Variables[k] = append([]string{}, v.Value...)
+		}
+	}
+
+	for _, k := range sortedKeys(m.Arguments) {
+		if key := m.Arguments[k].Key; key != "" {
+			k = key
+		}
+
+		result.Arguments = append(result.Arguments, k)
+	}
+
+	return result
+}
+
+// Catalog describes all modules, sorted by name
+func (c *Config) Catalog() []*ModuleInfo {
+	result := make([]*ModuleInfo, 0, len(c.Modules))
+	for _, name := range sortedKeys(c.Modules) {
+		module := c.Modules[name]
+		result = append(result, module.Info(name))
+	}
+
+	return result
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestConfigCatalog(t *testing.T) {
+	fixed := false
+	subject := &Config{
+		Modules: map[string]Module{
+			"snmp": Module{
+				Command: "/bin/check_snmp",
+				Timeout: NumberDuration(5000000000),
+				Arguments: map[string]Argument{
+					"community": Argument{Key: "-C", Value: LazyArray{"{{ .Vars.community | first }}"}},
+					"-H":        Argument{Value: LazyArray{"{{ .Vars.host | first }}"}},
+				},
+				Variables: map[string]Variable{
+					"community": Variable{Value: LazyArray{"public"}},
+					"host":      Variable{Value: LazyArray{"localhost"}},
+					"version":   Variable{Value: LazyArray{"2c"}, Overridable: &fixed},
+				},
+			},
+			"dummy": Module{Command: "/bin/check_dummy"},
+		},
+	}
+
+	want := []*ModuleInfo{
+		&ModuleInfo{
+			Name:      "dummy",
+			Command:   "/bin/check_dummy",
+			Variables: map[string][]string{},
+			Arguments: []string{},
+		},
+		&ModuleInfo{
+			Name:    "snmp",
+			Command: "/bin/check_snmp",
+			Timeout: NumberDuration(5000000000),
+			Variables: map[string][]string{
+				"community": []string{SecretToken},
+				"host":      []string{"localhost"},
+			},
+			Arguments: []string{"-H", "-C"},
+		},
+	}
+
+	assert.DeepEqual(t, want, subject.Catalog())
+}
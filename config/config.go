@@ -1,20 +1,44 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// ErrUnknownGeneration is returned when rolling back to
+// a generation which is not part of the history
+var ErrUnknownGeneration = errors.New("unknown config generation")
+
 // Config defines the configuration root node
 type Config struct {
-	Modules map[string]Module `yaml:"modules,omitempty"`
+	// Include lists glob patterns of additional configuration files,
+	// whose modules are merged into this one. Relative patterns are
+	// resolved against the directory of the including file.
+	Include []string          `yaml:"include,omitempty" json:"include,omitempty"`
+	Modules map[string]Module `yaml:"modules,omitempty" json:"modules,omitempty"`
+
+	// files are the paths of all configuration files the instance
+	// has been loaded from, starting with the main configuration file
+	files []string
+}
+
+// Files returns the paths of the configuration files
+// the instance has been loaded from
+func (c *Config) Files() []string {
+	return c.files
 }
 
 // YAML renders the instance as YAML representation
@@ -23,12 +47,88 @@ func (c *Config) MarshalYAML() ([]byte, error) {
 	return yaml.Marshal((*rawConfig)(c))
 }
 
-// SafeConfig is thread-safe Config instance provider
+// MarshalJSON renders the instance as JSON representation
+func (c *Config) MarshalJSON() ([]byte, error) {
+	type rawConfig Config
+	return json.Marshal((*rawConfig)(c))
+}
+
+// MarshalIcinga renders every module as Icinga CheckCommand object
+func (c *Config) MarshalIcinga() ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	names := make([]string, 0, len(c.Modules))
+	for name := range c.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		module := c.Modules[name]
+		data, err := module.MarshalIcinga(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DefaultHistorySize is the number of snapshots kept by SafeConfig
+const DefaultHistorySize = 10
+
+// Snapshot is an immutable version of the configuration
+type Snapshot struct {
+	Config *Config `json:"-"`
+	// Generation is incremented with every activated configuration
+	Generation int       `json:"generation"`
+	Hash       string    `json:"hash"`
+	LoadTime   time.Time `json:"load_time"`
+	// RollbackOf is the generation whose configuration
+	// has been restored by this snapshot, if any
+	RollbackOf int `json:"rollback_of,omitempty"`
+
+	// source and overlay produced the configuration
+	// and are restored along with it
+	source  *Config
+	overlay *Overlay
+}
+
+// Reload describes the outcome of a successful configuration reload
+type Reload struct {
+	// Generation is incremented with every successful reload
+	Generation int    `json:"generation"`
+	Hash       string `json:"hash"`
+	RollbackOf int    `json:"rollback_of,omitempty"`
+	Diff       *Diff  `json:"diff"`
+}
+
+// SafeConfig is thread-safe Config instance provider. Readers access
+// the active snapshot without locking, writers replace it atomically.
 type SafeConfig struct {
+	// guards the history and settings
 	sync.RWMutex
-	config              *Config
+	// serializes reloads, rollbacks and runtime module changes
+	update sync.Mutex
+	// source is the configuration most recently loaded from the files
+	source              *Config
+	overlay             *Overlay
+	overlayFile         string
+	current             atomic.Pointer[Snapshot]
+	history             []*Snapshot
+	historySize         int
 	configReloadSuccess prometheus.Gauge
 	configReloadSeconds prometheus.Gauge
+	configGeneration    prometheus.Gauge
+	configHash          prometheus.Gauge
+	moduleReady         *prometheus.GaugeVec
+	strict              bool
+	checker             ModuleChecker
 }
 
 // NewSafeConfig creates a new SafeConfig instance
@@ -43,20 +143,71 @@ func NewSafeConfig(namespace string, reg prometheus.Registerer) *SafeConfig {
 		Name:      "config_last_reload_success_timestamp_seconds",
 		Help:      "Timestamp of the last successful configuration reload.",
 	})
-	config := &Config{}
+	configGeneration := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_generation",
+		Help:      "Generation of the active configuration, incremented with every successful reload.",
+	})
+	configHash := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_hash",
+		Help:      "Hash of the active configuration.",
+	})
+	moduleReady := promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "module_ready",
+		Help:      "Whether the module passed the preflight checks of its plugin executable.",
+	}, []string{"module"})
 	result := &SafeConfig{
-		config:              config,
+		source:              &Config{},
+		overlay:             &Overlay{},
+		historySize:         DefaultHistorySize,
 		configReloadSuccess: configReloadSuccess,
 		configReloadSeconds: configReloadSeconds,
+		configGeneration:    configGeneration,
+		configHash:          configHash,
+		moduleReady:         moduleReady,
 	}
+	result.current.Store(&Snapshot{
+		Config: &Config{},
+	})
 
 	return result
 }
 
-// ReloadConfig reads the configuration from the given path and updates its
-// internal config instance with the parsed result
-func (sc *SafeConfig) ReloadConfig(confFile string, logger log.Logger) (err error) {
-	var c = &Config{}
+// LoadConfig reads and decodes the given configuration file
+// along with all files matching its include patterns and
+// resolves the module imports
+func LoadConfig(confFile string) (*Config, error) {
+	c, err := loadConfigFiles(confFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.resolveImports(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// loadConfigFiles reads and decodes the given configuration file along
+// with all files matching its include patterns without resolving imports
+func loadConfigFiles(confFile string) (*Config, error) {
+	l := newConfigLoader()
+	if err := l.load(confFile); err != nil {
+		return nil, err
+	}
+
+	return l.result, nil
+}
+
+// ReloadConfig reads the configuration from the given path and activates
+// the parsed result. The result describes the changes compared to the
+// previous configuration. Modules changed at runtime are applied on top
+// of the configuration files.
+func (sc *SafeConfig) ReloadConfig(confFile string, logger log.Logger) (result *Reload, err error) {
+	var source, c *Config
 	defer func() {
 		if err != nil {
 			sc.configReloadSuccess.Set(0)
@@ -66,36 +217,174 @@ func (sc *SafeConfig) ReloadConfig(confFile string, logger log.Logger) (err erro
 		}
 	}()
 
-	r, err := os.Open(confFile)
+	source, err = loadConfigFiles(confFile)
 	if err != nil {
-		return fmt.Errorf("error reading config file: %s", err)
+		return nil, err
 	}
-	defer r.Close()
-	decoder := yaml.NewDecoder(r)
-	decoder.KnownFields(true)
 
-	if err = decoder.Decode(c); err != nil {
-		return fmt.Errorf("error parsing config file: %s", err)
+	sc.update.Lock()
+	defer sc.update.Unlock()
+
+	c, err = sc.overlay.apply(source)
+	if err != nil {
+		return nil, err
 	}
 
-	sc.UpdateConfig(c)
+	for _, w := range c.Lint() {
+		level.Warn(logger).Log("msg", "Questionable module configuration", "module", w.Module, "argument", w.Argument, "warning", w.Message)
+	}
 
-	return nil
+	failures, err := sc.preflight(c, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.source = source
+
+	return sc.commit(c, failures, 0, logger), nil
 }
 
-// UpdateConfig replaces the internal config instance with the given one (thread-safe)
-func (sc *SafeConfig) UpdateConfig(c *Config) {
+// Rollback activates the configuration of the given generation again,
+// along with the configuration files and runtime module changes it
+// was created from. The restored configuration becomes a new
+// generation itself.
+func (sc *SafeConfig) Rollback(generation int, logger log.Logger) (*Reload, error) {
+	sc.update.Lock()
+	defer sc.update.Unlock()
+
+	var snapshot *Snapshot
+	for _, s := range sc.History() {
+		if s.Generation == generation {
+			snapshot = s
+			break
+		}
+	}
+
+	if snapshot == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownGeneration, generation)
+	}
+
+	failures, err := sc.preflight(snapshot.Config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc.overlayFile != "" {
+		if err := snapshot.overlay.save(sc.overlayFile); err != nil {
+			return nil, fmt.Errorf("unable to save overlay file: %s", err)
+		}
+	}
+
+	sc.source = snapshot.source
+	sc.overlay = snapshot.overlay
+
+	return sc.commit(snapshot.Config, failures, generation, logger), nil
+}
+
+// commit replaces the active snapshot with the given configuration,
+// which passed the preflight checks, and describes the changes.
+// The current source and overlay are recorded along with it.
+func (sc *SafeConfig) commit(c *Config, failures map[string]error, rollbackOf int, logger log.Logger) *Reload {
+	sc.recordReady(c, failures)
+	previous, snapshot := sc.store(c, rollbackOf)
+
+	sc.configGeneration.Set(float64(snapshot.Generation))
+	sc.configHash.Set(c.hashMetricValue())
+
+	result := &Reload{
+		Generation: snapshot.Generation,
+		Hash:       snapshot.Hash,
+		RollbackOf: rollbackOf,
+		Diff:       DiffConfig(previous.Config, c),
+	}
+
+	if previous.Generation > 0 {
+		logDiff(logger, result)
+	}
+
+	return result
+}
+
+// SetModuleChecker sets the checker validating modules
+// changed at runtime beyond their settings
+func (sc *SafeConfig) SetModuleChecker(checker ModuleChecker) {
 	sc.Lock()
-	sc.config = c
+	sc.checker = checker
 	sc.Unlock()
 }
 
-// ProvideConfig is a thread-safe visitor implementation, to gain access to the
-// internal config instance.
-func (sc *SafeConfig) ProvideConfig(visitor func(*Config)) {
+// SetStrict controls whether configurations with modules
+// failing the preflight checks are rejected
+func (sc *SafeConfig) SetStrict(strict bool) {
 	sc.Lock()
-	conf := sc.config
+	sc.strict = strict
 	sc.Unlock()
+}
+
+// SetHistorySize sets the number of snapshots to keep (at least one)
+func (sc *SafeConfig) SetHistorySize(size int) {
+	if size < 1 {
+		size = 1
+	}
 
-	visitor(conf)
+	sc.Lock()
+	sc.historySize = size
+	sc.trimHistory()
+	sc.Unlock()
+}
+
+// History returns the retained snapshots, oldest first
+func (sc *SafeConfig) History() []*Snapshot {
+	sc.RLock()
+	result := append([]*Snapshot(nil), sc.history...)
+	sc.RUnlock()
+
+	return result
+}
+
+// Snapshot returns the active snapshot
+func (sc *SafeConfig) Snapshot() *Snapshot {
+	return sc.current.Load()
+}
+
+// UpdateConfig activates the given config instance without any checks
+func (sc *SafeConfig) UpdateConfig(c *Config) {
+	sc.store(c, 0)
+}
+
+// store creates a new snapshot of the given config instance,
+// activates it and records it in the history
+func (sc *SafeConfig) store(c *Config, rollbackOf int) (previous, snapshot *Snapshot) {
+	sc.Lock()
+	defer sc.Unlock()
+
+	previous = sc.current.Load()
+	snapshot = &Snapshot{
+		Config:     c,
+		Generation: previous.Generation + 1,
+		Hash:       c.Hash(),
+		LoadTime:   time.Now(),
+		RollbackOf: rollbackOf,
+		source:     sc.source,
+		overlay:    sc.overlay,
+	}
+	sc.current.Store(snapshot)
+
+	sc.history = append(sc.history, snapshot)
+	sc.trimHistory()
+
+	return previous, snapshot
+}
+
+// trimHistory drops the oldest snapshots exceeding the history size
+func (sc *SafeConfig) trimHistory() {
+	if len(sc.history) > sc.historySize {
+		sc.history = append([]*Snapshot(nil), sc.history[len(sc.history)-sc.historySize:]...)
+	}
+}
+
+// ProvideConfig is a thread-safe visitor implementation, to gain access to the
+// active config instance. The instance must not be modified.
+func (sc *SafeConfig) ProvideConfig(visitor func(*Config)) {
+	visitor(sc.current.Load().Config)
 }
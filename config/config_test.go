@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gotest.tools/v3/assert"
+)
+
+func TestSafeConfigReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	subject := NewSafeConfig("test", prometheus.NewRegistry())
+
+	assert.NilError(t, os.WriteFile(file, []byte("modules:\n  dummy:\n    command: /bin/true\n"), 0644))
+	first, err := subject.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, first.Generation)
+	assert.DeepEqual(t, []string{"dummy"}, first.Diff.Added)
+
+	assert.NilError(t, os.WriteFile(file, []byte("modules: [\n"), 0644))
+	_, err = subject.ReloadConfig(file, log.NewNopLogger())
+	assert.Assert(t, err != nil)
+
+	assert.NilError(t, os.WriteFile(file, []byte("modules:\n  true:\n    command: /bin/true\n"), 0644))
+	second, err := subject.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.Equal(t, 2, second.Generation)
+	assert.DeepEqual(t, []string{"true"}, second.Diff.Added)
+	assert.DeepEqual(t, []string{"dummy"}, second.Diff.Removed)
+	assert.Assert(t, first.Hash != second.Hash)
+}
+
+func TestSafeConfigRollback(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	subject := NewSafeConfig("test", prometheus.NewRegistry())
+	subject.SetHistorySize(2)
+
+	for _, name := range []string{"one", "two", "three"} {
+		assert.NilError(t, os.WriteFile(file, []byte("modules:\n  "+name+":\n    command: /bin/true\n"), 0644))
+		_, err := subject.ReloadConfig(file, log.NewNopLogger())
+		assert.NilError(t, err)
+	}
+
+	history := subject.History()
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, 2, history[0].Generation)
+	assert.Equal(t, 3, history[1].Generation)
+
+	_, err := subject.Rollback(1, log.NewNopLogger())
+	assert.Assert(t, errors.Is(err, ErrUnknownGeneration))
+
+	result, err := subject.Rollback(2, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.Equal(t, 4, result.Generation)
+	assert.Equal(t, 2, result.RollbackOf)
+	assert.Equal(t, history[0].Hash, result.Hash)
+	assert.DeepEqual(t, []string{"two"}, result.Diff.Added)
+	assert.DeepEqual(t, []string{"three"}, result.Diff.Removed)
+
+	subject.ProvideConfig(func(c *Config) {
+		_, ok := c.Modules["two"]
+		assert.Assert(t, ok)
+	})
+	assert.Equal(t, 4, subject.Snapshot().Generation)
+}
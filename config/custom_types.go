@@ -2,7 +2,9 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -36,22 +38,31 @@ func (s *LazyArray) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
-// MarshalIcinga renders the array in the Icinga config syntax format
+// MarshalIcinga renders the array in the Icinga config syntax format.
+// Single items are rendered as string, as variables do not distinguish
+// between those and single item arrays.
 func (s LazyArray) MarshalIcinga(name string) ([]byte, error) {
 	buf := &bytes.Buffer{}
-	items := make([]string, len(s))
 
+	switch len(s) {
+	case 0:
+		icingaAssign(buf, name, "[]", "")
+
+		return buf.Bytes(), nil
+	case 1:
+		icingaAssign(buf, name, icingaLiteral(s[0]), "")
+
+		return buf.Bytes(), nil
+	}
+
+	items := make([]string, len(s))
 	for i, v := range s {
-		items[i] = fmt.Sprintf("%q", v)
+		items[i] = icingaLiteral(v)
 	}
 
-	buf.WriteString(name)
-	buf.WriteString(" = [")
-	buf.WriteString(strings.Join(items, ", "))
-	buf.WriteByte(']')
+	icingaAssign(buf, name, "[ "+strings.Join(items, ", ")+" ]", "")
 
 	return buf.Bytes(), nil
-
 }
 
 // NumberDuration is a time.Duration implementation
@@ -84,6 +95,16 @@ func (n *NumberDuration) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// MarshalYAML renders the instance as duration string
+func (n NumberDuration) MarshalYAML() (interface{}, error) {
+	return n.String(), nil
+}
+
+// MarshalJSON renders the instance as duration string
+func (n NumberDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.String())
+}
+
 // BooleanString is a string type, which can be unmarshaled
 // from a native bool value
 type BoolString string
@@ -104,3 +125,47 @@ func (b *BoolString) UnmarshalYAML(value *yaml.Node) error {
 	type rawBoolString BoolString
 	return value.Decode((*rawBoolString)(b))
 }
+
+// decodeKnownFields decodes the given node into out, rejecting mapping keys
+// without corresponding struct field. Unlike yaml.Decoder, Node.Decode does
+// not support the KnownFields setting, which would otherwise be lost in
+// custom unmarshalers.
+func decodeKnownFields(value *yaml.Node, out interface{}) error {
+	if value.Kind == yaml.MappingNode {
+		known := yamlFields(reflect.TypeOf(out).Elem())
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			if key := value.Content[i]; !known[key.Value] {
+				return fmt.Errorf("line %d: field %s not found", key.Line, key.Value)
+			}
+		}
+	}
+
+	return value.Decode(out)
+}
+
+// yamlFields returns the mapping keys of the given struct type
+func yamlFields(t reflect.Type) map[string]bool {
+	result := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		} else if strings.Contains(opts, "inline") {
+			for k := range yamlFields(field.Type) {
+				result[k] = true
+			}
+			continue
+		} else if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		result[name] = true
+	}
+
+	return result
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -203,6 +204,20 @@ func TestNumberDuration(t *testing.T) {
 	}
 }
 
+func TestNumberDurationMarshal(t *testing.T) {
+	type testFixture struct {
+		Unit NumberDuration `yaml:"unit,omitempty"`
+	}
+
+	got, err := yaml.Marshal(&testFixture{Unit: NumberDuration(90 * time.Second)})
+	assert.NilError(t, err)
+	assert.Equal(t, "unit: 90s\n", string(got))
+
+	got, err = json.Marshal(NumberDuration(90 * time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, `"90s"`, string(got))
+}
+
 func TestBoolString(t *testing.T) {
 	type testFixture struct {
 		Unit BoolString `yaml:"unit,omitempty"`
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Diff describes the differences between two configurations
+type Diff struct {
+	Added   []string              `json:"added,omitempty"`
+	Removed []string              `json:"removed,omitempty"`
+	Changed map[string]ModuleDiff `json:"changed,omitempty"`
+}
+
+// ModuleDiff describes the differences between two versions of a module
+type ModuleDiff struct {
+	// Settings lists the changed module settings other than
+	// arguments, variables and environment (e.g. command)
+	Settings    []string `json:"settings,omitempty"`
+	Arguments   *KeyDiff `json:"arguments,omitempty"`
+	Variables   *KeyDiff `json:"variables,omitempty"`
+	Environment *KeyDiff `json:"environment,omitempty"`
+}
+
+// KeyDiff describes the differences between two maps
+type KeyDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty reports whether the configurations are equal
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffConfig compares the modules of both configurations
+func DiffConfig(from, to *Config) *Diff {
+	result := &Diff{}
+
+	for _, name := range sortedKeys(to.Modules) {
+		if _, ok := from.Modules[name]; !ok {
+			result.Added = append(result.Added, name)
+		}
+	}
+
+	for _, name := range sortedKeys(from.Modules) {
+		next, ok := to.Modules[name]
+		if !ok {
+			result.Removed = append(result.Removed, name)
+			continue
+		}
+
+		if d, changed := diffModule(from.Modules[name], next); changed {
+			if result.Changed == nil {
+				result.Changed = make(map[string]ModuleDiff)
+			}
+
+			result.Changed[name] = d
+		}
+	}
+
+	return result
+}
+
+func diffModule(from, to Module) (ModuleDiff, bool) {
+	var result ModuleDiff
+
+	settings := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"import", from.Imports, to.Imports},
+		{"remove_arguments", from.RemoveArguments, to.RemoveArguments},
+		{"type", from.Type, to.Type},
+		{"command", from.Command, to.Command},
+		{"timeout", from.Timeout, to.Timeout},
+		{"nrpe", from.NRPE, to.NRPE},
+		{"nrpe_arguments", from.NRPEArguments, to.NRPEArguments},
+	}
+	for _, s := range settings {
+		if !reflect.DeepEqual(s.from, s.to) {
+			result.Settings = append(result.Settings, s.name)
+		}
+	}
+
+	result.Arguments = diffKeys(from.Arguments, to.Arguments)
+	result.Variables = diffKeys(from.Variables, to.Variables)
+	result.Environment = diffKeys(from.Environment, to.Environment)
+
+	changed := len(result.Settings) > 0 || result.Arguments != nil ||
+		result.Variables != nil || result.Environment != nil
+
+	return result, changed
+}
+
+// diffKeys compares both maps; the result is nil if they are equal
+func diffKeys[V any](from, to map[string]V) *KeyDiff {
+	result := &KeyDiff{}
+
+	for _, k := range sortedKeys(to) {
+		if _, ok := from[k]; !ok {
+			result.Added = append(result.Added, k)
+		}
+	}
+
+	for _, k := range sortedKeys(from) {
+		v, ok := to[k]
+		if !ok {
+			result.Removed = append(result.Removed, k)
+		} else if !reflect.DeepEqual(from[k], v) {
+			result.Changed = append(result.Changed, k)
+		}
+	}
+
+	if len(result.Added) == 0 && len(result.Removed) == 0 && len(result.Changed) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+// logDiff logs a summary of the reload and the changes of every module
+func logDiff(logger log.Logger, r *Reload) {
+	d := r.Diff
+	if d.Empty() {
+		level.Info(logger).Log("msg", "Config unchanged", "generation", r.Generation, "hash", r.Hash)
+		return
+	}
+
+	level.Info(logger).Log("msg", "Config changed", "generation", r.Generation, "hash", r.Hash, "rollback_of", r.RollbackOf,
+		"added", strings.Join(d.Added, ","), "removed", strings.Join(d.Removed, ","), "changed", len(d.Changed))
+
+	for _, name := range sortedKeys(d.Changed) {
+		m := d.Changed[name]
+		keyvals := []interface{}{"msg", "Module changed", "module", name}
+		if len(m.Settings) > 0 {
+			keyvals = append(keyvals, "settings", strings.Join(m.Settings, ","))
+		}
+
+		for _, k := range []struct {
+			name string
+			diff *KeyDiff
+		}{{"arguments", m.Arguments}, {"variables", m.Variables}, {"environment", m.Environment}} {
+			if k.diff == nil {
+				continue
+			}
+
+			keyvals = append(keyvals,
+				k.name+"_added", strings.Join(k.diff.Added, ","),
+				k.name+"_removed", strings.Join(k.diff.Removed, ","),
+				k.name+"_changed", strings.Join(k.diff.Changed, ","),
+			)
+		}
+
+		level.Info(logger).Log(keyvals...)
+	}
+}
+
+// Hash returns the hex encoded SHA-256 checksum
+// of the JSON representation of the instance
+func (c *Config) Hash() string {
+	return hex.EncodeToString(c.hashSum())
+}
+
+// hashMetricValue converts the leading 48 bits of the config
+// checksum into a value which can be represented by a gauge
+func (c *Config) hashMetricValue() float64 {
+	buf := make([]byte, 8)
+	copy(buf, c.hashSum()[:6])
+
+	return float64(binary.LittleEndian.Uint64(buf))
+}
+
+func (c *Config) hashSum() []byte {
+	// map keys are sorted by the JSON encoder, rendering a
+	// stable result; errors are limited to unsupported types
+	data, _ := json.Marshal(c)
+	h := sha256.New()
+	h.Write(data)
+
+	// values read from files are not part of the rendered
+	// configuration, but their changes need to be reflected
+	for _, name := range sortedKeys(c.Modules) {
+		variables := c.Modules[name].Variables
+		for _, k := range sortedKeys(variables) {
+			if v := variables[k]; v.ValueFile != "" {
+				h.Write([]byte{0})
+				h.Write([]byte(name + "\x00" + k))
+				for _, value := range v.Value {
+					h.Write([]byte{0})
+					h.Write([]byte(value))
+				}
+			}
+		}
+	}
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDiffConfig(t *testing.T) {
+	from := &Config{
+		Modules: map[string]Module{
+			"dummy": Module{Command: "/bin/check_dummy"},
+			"http": Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]Argument{
+					"-H": Argument{Value: LazyArray{"{{ .Vars.host | first }}"}},
+					"-u": Argument{Value: LazyArray{"/"}},
+				},
+				Variables: map[string]Variable{
+					"host": Variable{Value: LazyArray{"localhost"}},
+				},
+			},
+			"ping": Module{Command: "/bin/check_ping"},
+		},
+	}
+	to := &Config{
+		Modules: map[string]Module{
+			"disk": Module{Command: "/bin/check_disk"},
+			"http": Module{
+				Command: "/usr/bin/check_http",
+				Arguments: map[string]Argument{
+					"-H": Argument{Value: LazyArray{"{{ .Vars.host | first }}"}},
+					"-u": Argument{Value: LazyArray{"/health"}},
+					"-S": Argument{Condition: "true"},
+				},
+				Variables: map[string]Variable{
+					"host": Variable{Value: LazyArray{"localhost"}},
+				},
+				Environment: map[string]string{
+					"LANG": "C",
+				},
+			},
+			"ping": Module{Command: "/bin/check_ping"},
+		},
+	}
+
+	want := &Diff{
+		Added:   []string{"disk"},
+		Removed: []string{"dummy"},
+		Changed: map[string]ModuleDiff{
+			"http": ModuleDiff{
+				Settings: []string{"command"},
+				Arguments: &KeyDiff{
+					Added:   []string{"-S"},
+					Changed: []string{"-u"},
+				},
+				Environment: &KeyDiff{
+					Added: []string{"LANG"},
+				},
+			},
+		},
+	}
+
+	assert.DeepEqual(t, want, DiffConfig(from, to))
+	assert.Assert(t, DiffConfig(to, to).Empty())
+}
+
+func TestConfigHash(t *testing.T) {
+	a := &Config{Modules: map[string]Module{"dummy": Module{Command: "/bin/check_dummy"}}}
+	b := &Config{Modules: map[string]Module{"dummy": Module{Command: "/bin/check_dummy"}}}
+	c := &Config{Modules: map[string]Module{"dummy": Module{Command: "/bin/true"}}}
+
+	assert.Equal(t, a.Hash(), b.Hash())
+	assert.Equal(t, a.hashMetricValue(), b.hashMetricValue())
+	assert.Assert(t, a.Hash() != c.Hash())
+	assert.Assert(t, a.hashMetricValue() != c.hashMetricValue())
+}
+
+func TestConfigHashValueFile(t *testing.T) {
+	variable := func(value string) map[string]Variable {
+		return map[string]Variable{"community": Variable{Value: LazyArray{value}, ValueFile: "/etc/community", Secret: true}}
+	}
+	a := &Config{Modules: map[string]Module{"snmp": Module{Command: "/bin/check_snmp", Variables: variable("public")}}}
+	b := &Config{Modules: map[string]Module{"snmp": Module{Command: "/bin/check_snmp", Variables: variable("private")}}}
+
+	assert.Assert(t, a.Hash() != b.Hash())
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
+)
+
+// IcingaPlaceholder is exported in place of template
+// expressions which have no Icinga equivalent
+const IcingaPlaceholder = "{{ return null }}"
+
+// templateBuiltins are the builtin template functions, which are required
+// to parse arbitrary templates. The parser only checks for the presence
+// of a non-nil value.
+var templateBuiltins = map[string]interface{}{
+	"and": true, "call": true, "html": true, "index": true, "slice": true,
+	"js": true, "len": true, "not": true, "or": true, "print": true,
+	"printf": true, "println": true, "urlquery": true,
+	"eq": true, "ge": true, "gt": true, "le": true, "lt": true, "ne": true,
+}
+
+// icingaMacroFunctions are template functions which do not alter
+// a variable in a way that can not be expressed using runtime macros
+var icingaMacroFunctions = map[string]int{
+	"compact": 0,
+	"first":   0,
+	"join":    1,
+	"lines":   0,
+	"strval":  0,
+	"trim":    0,
+	"uniq":    0,
+}
+
+// icingaQuote renders the given string as Icinga string literal
+func icingaQuote(s string) string {
+	buf := &strings.Builder{}
+	buf.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
+// icingaLiteral renders the given string as Icinga string literal,
+// escaping any dollar signs to prevent macro resolution
+func icingaLiteral(s string) string {
+	return icingaQuote(strings.ReplaceAll(s, "$", "$$"))
+}
+
+// icingaAttribute renders the attribute path for the given dictionary
+// key, using the indexer syntax for keys which are no valid identifier
+func icingaAttribute(dict, key string) string {
+	valid := key != ""
+	for i, c := range key {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			valid = false
+			break
+		}
+	}
+
+	if valid {
+		return dict + "." + key
+	}
+
+	return dict + "[" + icingaQuote(key) + "]"
+}
+
+// icingaValue renders a template string as Icinga expression. Runtime macros
+// are kept as they are, templates are translated into runtime macros if
+// possible. Untranslatable templates yield the IcingaPlaceholder and
+// a comment containing the original template.
+func icingaValue(s string) (expr, comment string) {
+	if !strings.Contains(s, template.TemplateToken) {
+		return icingaQuote(s), ""
+	}
+
+	if t, ok := icingaTemplate(s); ok {
+		return icingaQuote(t), ""
+	}
+
+	return IcingaPlaceholder, "unsupported template: " + strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// icingaBool renders a boolean or template string as Icinga expression
+func icingaBool(b BoolString) (expr, comment string) {
+	if v, err := strconv.ParseBool(string(b)); err == nil {
+		return strconv.FormatBool(v), ""
+	}
+
+	if lambda, ok := icingaCondition(string(b)); ok {
+		return lambda, ""
+	}
+
+	return icingaValue(string(b))
+}
+
+// icingaCondition translates conditional templates testing a variable
+// for presence ({{ if .Vars.x }}true{{ end }}) into a function expression
+func icingaCondition(s string) (string, bool) {
+	trees, err := parse.Parse("icinga", s, "", "", map[string]interface{}(template.Functions), templateBuiltins)
+	if err != nil || trees["icinga"] == nil || len(trees["icinga"].Root.Nodes) != 1 {
+		return "", false
+	}
+
+	n, ok := trees["icinga"].Root.Nodes[0].(*parse.IfNode)
+	if !ok || n.ElseList != nil && strings.TrimSpace(n.ElseList.String()) != "false" {
+		return "", false
+	}
+
+	if n.List == nil || strings.TrimSpace(n.List.String()) != "true" {
+		return "", false
+	}
+
+	name, ok := icingaMacro(n.Pipe)
+	if !ok {
+		return "", false
+	}
+
+	return "{{ return len(macro(" + icingaQuote("$"+name+"$") + ")) > 0 }}", true
+}
+
+// icingaTemplate translates the given template into a string using
+// runtime macros. Only variable references, optionally processed by
+// functions without any effect on the value, can be translated.
+func icingaTemplate(s string) (string, bool) {
+	trees, err := parse.Parse("icinga", s, "", "", map[string]interface{}(template.Functions), templateBuiltins)
+	if err != nil || trees["icinga"] == nil {
+		return "", false
+	}
+
+	buf := &strings.Builder{}
+	for _, n := range trees["icinga"].Root.Nodes {
+		switch x := n.(type) {
+		case *parse.TextNode:
+			buf.WriteString(strings.ReplaceAll(string(x.Text), "$", "$$"))
+		case *parse.ActionNode:
+			name, ok := icingaMacro(x.Pipe)
+			if !ok {
+				return "", false
+			}
+
+			buf.WriteString("$" + name + "$")
+		default:
+			return "", false
+		}
+	}
+
+	return buf.String(), true
+}
+
+func icingaMacro(pipe *parse.PipeNode) (string, bool) {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 || len(pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 2 || field.Ident[0] != "Vars" {
+		return "", false
+	}
+
+	for _, cmd := range pipe.Cmds[1:] {
+		fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			return "", false
+		}
+
+		if args, ok := icingaMacroFunctions[fn.Ident]; !ok || len(cmd.Args) != args+1 {
+			return "", false
+		}
+	}
+
+	return field.Ident[1], true
+}
+
+// icingaIndent prefixes every line of the given
+// data with the provided indentation
+func icingaIndent(buf *bytes.Buffer, data []byte, indent string) {
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		buf.WriteString(indent)
+		buf.Write(line)
+	}
+}
+
+// icingaAssign writes an assignment, preceded by the comment if present
+func icingaAssign(buf *bytes.Buffer, name, expr, comment string) {
+	if comment != "" {
+		buf.WriteString("// ")
+		buf.WriteString(comment)
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteString(name)
+	buf.WriteString(" = ")
+	buf.WriteString(expr)
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIcingaQuote(t *testing.T) {
+	testCases := map[string]string{
+		"":              `""`,
+		"plain":         `"plain"`,
+		`say "hi"`:      `"say \"hi\""`,
+		`C:\plugins`:    `"C:\\plugins"`,
+		"line\nbreak\t": `"line\nbreak\t"`,
+		"$macro$":       `"$macro$"`,
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.Equal(t, want, icingaQuote(have))
+		})
+	}
+}
+
+func TestIcingaAttribute(t *testing.T) {
+	testCases := map[string]string{
+		"http_vhost": "vars.http_vhost",
+		"address6":   "vars.address6",
+		"my-var":     `vars["my-var"]`,
+		"6address":   `vars["6address"]`,
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.Equal(t, want, icingaAttribute("vars", have))
+		})
+	}
+}
+
+func TestIcingaValue(t *testing.T) {
+	type testCase struct {
+		have        string
+		want        string
+		wantComment bool
+	}
+
+	testCases := map[string]testCase{
+		"literal": testCase{
+			have: "-4",
+			want: `"-4"`,
+		},
+		"macro": testCase{
+			have: "$address$",
+			want: `"$address$"`,
+		},
+		"variable": testCase{
+			have: "{{ .Vars.address }}",
+			want: `"$address$"`,
+		},
+		"pipeline": testCase{
+			have: `{{ .Vars.address | first }}:{{ .Vars.port | join "," }}`,
+			want: `"$address$:$port$"`,
+		},
+		"escaped text": testCase{
+			have: "{{ .Vars.price }}$",
+			want: `"$price$$$"`,
+		},
+		"environment": testCase{
+			have:        "{{ .Env.HOME }}",
+			want:        IcingaPlaceholder,
+			wantComment: true,
+		},
+		"function": testCase{
+			have:        "{{ .Vars.address | first | upper }}",
+			want:        IcingaPlaceholder,
+			wantComment: true,
+		},
+		"broken": testCase{
+			have:        "{{ .Vars.address",
+			want:        IcingaPlaceholder,
+			wantComment: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, comment := icingaValue(tc.have)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantComment, comment != "")
+		})
+	}
+}
+
+func TestIcingaBool(t *testing.T) {
+	testCases := map[string]string{
+		"true":                            "true",
+		"0":                               "false",
+		"$ssl$":                           `"$ssl$"`,
+		"{{ .Vars.ssl | first }}":         `"$ssl$"`,
+		"{{ if .Vars.ssl }}true{{ end }}": `{{ return len(macro("$ssl$")) > 0 }}`,
+		"{{ if .Vars.ssl }}true{{else}}false{{ end }}": `{{ return len(macro("$ssl$")) > 0 }}`,
+		"{{ if .Vars.ssl }}false{{ end }}":             IcingaPlaceholder,
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			got, _ := icingaBool(BoolString(have))
+
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestConfigMarshalIcinga(t *testing.T) {
+	have := &Config{
+		Modules: map[string]Module{
+			"http": Module{
+				Command: "/usr/lib/nagios/plugins/check_http",
+				Timeout: NumberDuration(30000000000),
+				Arguments: map[string]Argument{
+					"-u": Argument{
+						Value:     LazyArray{"{{ .Vars.uri | first }}", `x"y`},
+						Required:  "false",
+						RepeatKey: "true",
+						SkipKey:   "false",
+						Separator: " ",
+					},
+					"-H": Argument{
+						Value:    LazyArray{"$http_vhost$"},
+						Order:    -1,
+						Required: "true",
+					},
+					"-S": Argument{
+						Condition: "{{ .Vars.ssl | len }}",
+						Separator: "=",
+					},
+				},
+				Variables: map[string]Variable{
+					"http_vhost": Variable{Value: LazyArray{"localhost"}},
+					"my-var":     Variable{Value: LazyArray{"a", "b$"}},
+				},
+				Environment: map[string]string{
+					"LANG": "C",
+				},
+				Macros: true,
+			},
+			"dummy": Module{
+				Command: "/usr/lib/nagios/plugins/check_dummy",
+				Arguments: map[string]Argument{
+					"TEXT": Argument{Value: LazyArray{"ab$c$d"}, SkipKey: "true"},
+				},
+			},
+		},
+	}
+	want := `object CheckCommand "dummy" {
+  command = [ "/usr/lib/nagios/plugins/check_dummy" ]
+  arguments = {
+    "TEXT" = {
+      value = "ab$$c$$d"
+      skip_key = true
+    }
+  }
+}
+
+object CheckCommand "http" {
+  command = [ "/usr/lib/nagios/plugins/check_http" ]
+  timeout = 30s
+  env = {
+    "LANG" = "C"
+  }
+  arguments = {
+    "-H" = {
+      value = "$http_vhost$"
+      order = -1
+      required = true
+    }
+    "-S" = {
+      // unsupported template: {{ .Vars.ssl | len }}
+      set_if = {{ return null }}
+      separator = "="
+    }
+    "-u" = {
+      value = [ "$uri$", "x\"y" ]
+    }
+  }
+  vars.http_vhost = "localhost"
+  vars["my-var"] = [ "a", "b$$" ]
+}
+`
+
+	got, err := have.MarshalIcinga()
+
+	assert.NilError(t, err)
+	assert.Equal(t, want, string(got))
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configLoader merges the modules of a configuration file
+// and its includes into a single Config instance
+type configLoader struct {
+	result *Config
+	// sources maps module names to the file declaring them
+	sources map[string]string
+	seen    map[string]bool
+}
+
+func newConfigLoader() *configLoader {
+	result := &configLoader{
+		result:  &Config{},
+		sources: make(map[string]string),
+		seen:    make(map[string]bool),
+	}
+
+	return result
+}
+
+// load decodes the given file, merges its modules and recursively
+// loads its includes. Files matched more than once are loaded once.
+func (l *configLoader) load(file string) error {
+	file = filepath.Clean(file)
+	if l.seen[file] {
+		return nil
+	}
+	l.seen[file] = true
+
+	c, err := decodeConfigFile(file)
+	if err != nil {
+		return err
+	}
+
+	l.result.files = append(l.result.files, file)
+	for name, module := range c.Modules {
+		if source, ok := l.sources[name]; ok {
+			return fmt.Errorf("module %q is defined in both %s and %s", name, source, file)
+		}
+
+		if err := module.readValueFiles(filepath.Dir(file)); err != nil {
+			return fmt.Errorf("error loading config file %s: module %q: %s", file, name, err)
+		}
+
+		if l.result.Modules == nil {
+			l.result.Modules = make(map[string]Module)
+		}
+
+		l.sources[name] = file
+		l.result.Modules[name] = module
+	}
+
+	for _, pattern := range c.Include {
+		matches, err := includeFiles(file, pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range matches {
+			if err := l.load(match); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// includeFiles resolves the given include pattern relative to the
+// including file. Patterns without wildcards must match a file,
+// whereas wildcards may not match anything (e.g. an empty conf.d).
+func includeFiles(file, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(file), pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %q in %s: %s", pattern, file, err)
+	}
+
+	if len(matches) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+		return nil, fmt.Errorf("include file %s referenced in %s does not exist", pattern, file)
+	}
+
+	result := make([]string, 0, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %s", err)
+		} else if info.IsDir() {
+			continue
+		}
+
+		result = append(result, match)
+	}
+
+	return result, nil
+}
+
+// decodeConfigFile decodes a single configuration file
+// without processing its includes
+func decodeConfigFile(file string) (*Config, error) {
+	var c = &Config{}
+
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %s", err)
+	}
+	defer r.Close()
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %s", file, err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func TestLoadConfig(t *testing.T) {
+	type testCase struct {
+		have        map[string]string
+		wantModules []string
+		wantFiles   []string
+		wantError   string
+	}
+
+	testCases := map[string]testCase{
+		"single file": testCase{
+			have: map[string]string{
+				"main.yml": "modules:\n  dummy:\n    command: /bin/true\n",
+			},
+			wantModules: []string{"dummy"},
+			wantFiles:   []string{"main.yml"},
+		},
+		"includes": testCase{
+			have: map[string]string{
+				"main.yml":         "include: [conf.d/*.yml, extra.yml]\nmodules:\n  dummy:\n    command: /bin/true\n",
+				"conf.d/http.yml":  "modules:\n  http:\n    command: /bin/check_http\n",
+				"conf.d/ping.yml":  "include: [../extra.yml]\nmodules:\n  ping:\n    command: /bin/check_ping\n",
+				"conf.d/other.txt": "garbage",
+				"extra.yml":        "modules:\n  disk:\n    command: /bin/check_disk\n",
+			},
+			wantModules: []string{"disk", "dummy", "http", "ping"},
+			wantFiles:   []string{"main.yml", "conf.d/http.yml", "conf.d/ping.yml", "extra.yml"},
+		},
+		"empty directory": testCase{
+			have: map[string]string{
+				"main.yml": "include: [conf.d/*.yml]\nmodules:\n  dummy:\n    command: /bin/true\n",
+			},
+			wantModules: []string{"dummy"},
+			wantFiles:   []string{"main.yml"},
+		},
+		"relative value file": testCase{
+			have: map[string]string{
+				"main.yml":         "include: [conf.d/*.yml]\n",
+				"conf.d/snmp.yml":  "modules:\n  snmp:\n    command: /bin/check_snmp\n    variables:\n      community: {value_file: community, secret: true}\n",
+				"conf.d/community": "public\n",
+			},
+			wantModules: []string{"snmp"},
+			wantFiles:   []string{"main.yml", "conf.d/snmp.yml"},
+		},
+		"missing value file": testCase{
+			have: map[string]string{
+				"main.yml": "modules:\n  snmp:\n    command: /bin/check_snmp\n    variables:\n      community: {value_file: community}\n",
+			},
+			wantError: `module "snmp": variable "community": Unable to read variable value file`,
+		},
+		"duplicate module": testCase{
+			have: map[string]string{
+				"main.yml":        "include: [conf.d/*.yml]\nmodules:\n  http:\n    command: /bin/check_http\n",
+				"conf.d/http.yml": "modules:\n  http:\n    command: /bin/check_http\n",
+			},
+			wantError: `module "http" is defined in both`,
+		},
+		"unknown module setting": testCase{
+			have: map[string]string{
+				"main.yml": "modules:\n  dummy:\n    comand: /bin/true\n",
+			},
+			wantError: "field comand not found",
+		},
+		"missing include": testCase{
+			have: map[string]string{
+				"main.yml": "include: [missing.yml]\n",
+			},
+			wantError: "does not exist",
+		},
+		"invalid include": testCase{
+			have: map[string]string{
+				"main.yml":        "include: [conf.d/*.yml]\n",
+				"conf.d/typo.yml": "modules:\n  http: [\n",
+			},
+			wantError: "typo.yml",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			dir := writeConfigFiles(t, tc.have)
+			got, err := LoadConfig(filepath.Join(dir, "main.yml"))
+
+			if tc.wantError != "" {
+				assert.ErrorContains(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+
+			gotModules := make([]string, 0, len(got.Modules))
+			for name := range got.Modules {
+				gotModules = append(gotModules, name)
+			}
+			sort.Strings(gotModules)
+
+			wantFiles := make([]string, len(tc.wantFiles))
+			for i, f := range tc.wantFiles {
+				wantFiles[i] = filepath.Join(dir, f)
+			}
+
+			assert.DeepEqual(t, tc.wantModules, gotModules)
+			for _, module := range got.Modules {
+				for _, v := range module.Variables {
+					assert.Assert(t, v.ValueFile == "" || filepath.IsAbs(v.ValueFile))
+				}
+			}
+			assert.DeepEqual(t, wantFiles, got.Files())
+		})
+	}
+}
+
+func TestLoadConfigDuplicateSources(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yml": "include: [a.yml, b.yml]\n",
+		"a.yml":    "modules:\n  http:\n    command: /bin/check_http\n",
+		"b.yml":    "modules:\n  http:\n    command: /bin/check_http\n",
+	})
+
+	_, err := LoadConfig(filepath.Join(dir, "main.yml"))
+
+	assert.Assert(t, cmp.ErrorContains(err, filepath.Join(dir, "a.yml")))
+	assert.Assert(t, cmp.ErrorContains(err, filepath.Join(dir, "b.yml")))
+}
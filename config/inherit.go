@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	importPending = iota
+	importResolving
+	importResolved
+)
+
+// importResolver merges the settings of imported modules into the
+// importing ones, resolving the imports of the parents first
+type importResolver struct {
+	modules map[string]Module
+	state   map[string]int
+}
+
+// resolveImports replaces every module importing others with the merged
+// result of its parents and itself
+func (c *Config) resolveImports() error {
+	r := &importResolver{
+		modules: c.Modules,
+		state:   make(map[string]int, len(c.Modules)),
+	}
+
+	for _, name := range sortedKeys(c.Modules) {
+		if err := r.resolve(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *importResolver) resolve(name string, path []string) error {
+	switch r.state[name] {
+	case importResolved:
+		return nil
+	case importResolving:
+		return fmt.Errorf("module import cycle: %s", strings.Join(append(path, name), " -> "))
+	}
+
+	module := r.modules[name]
+	if len(module.Imports) == 0 {
+		r.state[name] = importResolved
+		return nil
+	}
+
+	r.state[name] = importResolving
+	path = append(path, name)
+
+	inherited := Module{}
+	for _, parent := range module.Imports {
+		if _, ok := r.modules[parent]; !ok {
+			return fmt.Errorf("module %q imports unknown module %q", name, parent)
+		}
+
+		if err := r.resolve(parent, path); err != nil {
+			return err
+		}
+
+		inherited = mergeModules(inherited, r.modules[parent])
+	}
+
+	for _, arg := range module.RemoveArguments {
+		if _, ok := inherited.Arguments[arg]; !ok {
+			return fmt.Errorf("module %q removes argument %q, which is not inherited", name, arg)
+		}
+
+		delete(inherited.Arguments, arg)
+	}
+
+	result := mergeModules(inherited, module)
+	if err := result.validate(); err != nil {
+		return fmt.Errorf("module %q: %s", name, err)
+	}
+
+	r.modules[name] = result
+	r.state[name] = importResolved
+
+	return nil
+}
+
+// mergeModules creates a new module with the settings of the base module,
+// overridden by the ones set in the other module. Arguments, variables and
+// environment are merged on key level.
+func mergeModules(base, override Module) Module {
+	result := base
+	result.Imports = override.Imports
+	result.RemoveArguments = override.RemoveArguments
+
+	if override.Type != "" {
+		result.Type = override.Type
+	}
+
+	if override.Command != "" {
+		result.Command = override.Command
+	}
+
+	if override.Timeout != 0 {
+		result.Timeout = override.Timeout
+	}
+
+	if override.NRPE != nil {
+		result.NRPE = override.NRPE
+	}
+
+	if override.NRPEArguments != nil {
+		result.NRPEArguments = override.NRPEArguments
+	}
+
+	if override.Macros {
+		result.Macros = true
+	}
+
+	result.Arguments = mergeMaps(base.Arguments, override.Arguments)
+	result.Variables = mergeMaps(base.Variables, override.Variables)
+	result.Environment = mergeMaps(base.Environment, override.Environment)
+
+	return result
+}
+
+// mergeMaps copies both maps into a new one, with the
+// values of the override taking precedence
+func mergeMaps[V any](base, override map[string]V) map[string]V {
+	if base == nil && override == nil {
+		return nil
+	}
+
+	result := make(map[string]V, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+
+	for k, v := range override {
+		result[k] = v
+	}
+
+	return result
+}
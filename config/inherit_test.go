@@ -0,0 +1,160 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestConfigResolveImports(t *testing.T) {
+	http := Module{
+		Command: "/bin/check_http",
+		Timeout: NumberDuration(10000000000),
+		Arguments: map[string]Argument{
+			"-H": Argument{Value: LazyArray{"{{ .Vars.host | first }}"}},
+			"-u": Argument{Value: LazyArray{"{{ .Vars.uri | first }}"}},
+		},
+		Variables: map[string]Variable{
+			"host": Variable{Value: LazyArray{"localhost"}},
+			"uri":  Variable{Value: LazyArray{"/"}},
+		},
+		Environment: map[string]string{
+			"LANG": "C",
+		},
+	}
+
+	type testCase struct {
+		have      map[string]Module
+		name      string
+		want      Module
+		wantError string
+	}
+
+	testCases := map[string]testCase{
+		"override": testCase{
+			have: map[string]Module{
+				"http": http,
+				"https": Module{
+					Imports: []string{"http"},
+					Arguments: map[string]Argument{
+						"-S": Argument{Condition: "true"},
+						"-u": Argument{Value: LazyArray{"/health"}},
+					},
+					Variables: map[string]Variable{
+						"host": Variable{Value: LazyArray{"example.com"}},
+					},
+				},
+			},
+			name: "https",
+			want: Module{
+				Imports: []string{"http"},
+				Command: "/bin/check_http",
+				Timeout: NumberDuration(10000000000),
+				Arguments: map[string]Argument{
+					"-H": Argument{Value: LazyArray{"{{ .Vars.host | first }}"}},
+					"-S": Argument{Condition: "true"},
+					"-u": Argument{Value: LazyArray{"/health"}},
+				},
+				Variables: map[string]Variable{
+					"host": Variable{Value: LazyArray{"example.com"}},
+					"uri":  Variable{Value: LazyArray{"/"}},
+				},
+				Environment: map[string]string{
+					"LANG": "C",
+				},
+			},
+		},
+		"import order": testCase{
+			have: map[string]Module{
+				"a":     Module{Command: "/bin/a", Variables: map[string]Variable{"x": Variable{Value: LazyArray{"a"}}, "y": Variable{Value: LazyArray{"a"}}}},
+				"b":     Module{Command: "/bin/b", Variables: map[string]Variable{"x": Variable{Value: LazyArray{"b"}}}},
+				"child": Module{Imports: []string{"a", "b"}},
+			},
+			name: "child",
+			want: Module{
+				Imports:   []string{"a", "b"},
+				Command:   "/bin/b",
+				Variables: map[string]Variable{"x": Variable{Value: LazyArray{"b"}}, "y": Variable{Value: LazyArray{"a"}}},
+			},
+		},
+		"transitive": testCase{
+			have: map[string]Module{
+				"grandchild": Module{Imports: []string{"child"}, Environment: map[string]string{"TZ": "UTC"}},
+				"child":      Module{Imports: []string{"http"}, RemoveArguments: []string{"-u"}},
+				"http":       http,
+			},
+			name: "grandchild",
+			want: Module{
+				Imports: []string{"child"},
+				Command: "/bin/check_http",
+				Timeout: NumberDuration(10000000000),
+				Arguments: map[string]Argument{
+					"-H": Argument{Value: LazyArray{"{{ .Vars.host | first }}"}},
+				},
+				Variables: map[string]Variable{
+					"host": Variable{Value: LazyArray{"localhost"}},
+					"uri":  Variable{Value: LazyArray{"/"}},
+				},
+				Environment: map[string]string{
+					"LANG": "C",
+					"TZ":   "UTC",
+				},
+			},
+		},
+		"remove unknown argument": testCase{
+			have: map[string]Module{
+				"http":  http,
+				"https": Module{Imports: []string{"http"}, RemoveArguments: []string{"-S"}},
+			},
+			wantError: `module "https" removes argument "-S", which is not inherited`,
+		},
+		"unknown import": testCase{
+			have: map[string]Module{
+				"https": Module{Imports: []string{"htp"}},
+			},
+			wantError: `module "https" imports unknown module "htp"`,
+		},
+		"cycle": testCase{
+			have: map[string]Module{
+				"a": Module{Imports: []string{"b"}},
+				"b": Module{Imports: []string{"c"}},
+				"c": Module{Imports: []string{"a"}},
+			},
+			wantError: "module import cycle: a -> b -> c -> a",
+		},
+		"inherited validation": testCase{
+			have: map[string]Module{
+				"base":  Module{Command: "check_load"},
+				"child": Module{Imports: []string{"base"}, Type: ModuleTypeNRPE},
+			},
+			wantError: `module "child": Module type "nrpe" requires the nrpe settings`,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			subject := &Config{Modules: tc.have}
+			err := subject.resolveImports()
+
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, subject.Modules[tc.name])
+		})
+	}
+}
+
+func TestConfigResolveImportsParentUnchanged(t *testing.T) {
+	subject := &Config{
+		Modules: map[string]Module{
+			"base":  Module{Command: "/bin/true", Variables: map[string]Variable{"a": Variable{Value: LazyArray{"1"}}}},
+			"child": Module{Imports: []string{"base"}, Variables: map[string]Variable{"b": Variable{Value: LazyArray{"2"}}}},
+		},
+	}
+
+	assert.NilError(t, subject.resolveImports())
+	assert.DeepEqual(t, map[string]Variable{"a": Variable{Value: LazyArray{"1"}}}, subject.Modules["base"].Variables)
+}
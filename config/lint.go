@@ -0,0 +1,354 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
+)
+
+// LintWarning describes a questionable, but valid construct in a module.
+// Argument is empty if the warning is not related to a specific argument.
+type LintWarning struct {
+	Module   string
+	Argument string
+	Message  string
+}
+
+func (w LintWarning) String() string {
+	if w.Argument == "" {
+		return fmt.Sprintf("module %q: %s", w.Module, w.Message)
+	}
+
+	return fmt.Sprintf("module %q argument %q: %s", w.Module, w.Argument, w.Message)
+}
+
+// Lint inspects every module for questionable constructs
+// and returns the findings ordered by module name
+func (c *Config) Lint() []LintWarning {
+	var result []LintWarning
+	for _, name := range sortedKeys(c.Modules) {
+		module := c.Modules[name]
+		result = append(result, module.Lint(name)...)
+	}
+
+	return result
+}
+
+// Lint inspects the module for references to undeclared variables,
+// declared but unreferenced variables and environment entries,
+// arguments rendering the same key and arguments sharing their order
+func (m *Module) Lint(name string) []LintWarning {
+	var result []LintWarning
+
+	warn := func(arg, format string, a ...interface{}) {
+		result = append(result, LintWarning{Module: name, Argument: arg, Message: fmt.Sprintf(format, a...)})
+	}
+
+	used := newTemplateRefs(m.Macros)
+	undeclared := func(arg, prefix string, refs *templateRefs) {
+		for _, v := range refs.sortedVars() {
+			if _, ok := m.Variables[v]; !ok {
+				warn(arg, "%svariable %q is not declared", prefix, v)
+			}
+		}
+
+		for _, e := range refs.sortedEnv() {
+			if _, ok := m.Environment[e]; !ok {
+				warn(arg, "%senvironment variable %q is not declared", prefix, e)
+			}
+		}
+
+		used.merge(refs)
+	}
+
+	keys := sortedKeys(m.Arguments)
+	for _, k := range keys {
+		refs := newTemplateRefs(m.Macros)
+		for _, s := range m.Arguments[k].templates() {
+			refs.add(s)
+
+			if !m.Macros && !strings.Contains(s, template.TemplateToken) {
+				for _, v := range MacroNames(s) {
+					warn(k, "runtime macro %q is passed literally, as macros are not enabled", "$"+v+"$")
+				}
+			}
+		}
+
+		undeclared(k, "", refs)
+	}
+
+	if m.NRPE != nil {
+		refs := newTemplateRefs(false)
+		refs.add(m.NRPE.Address)
+
+		undeclared("", "NRPE address: ", refs)
+	}
+
+	if !used.dynamic {
+		for _, v := range sortedKeys(m.Variables) {
+			if !used.vars[v] {
+				warn("", "variable %q is not referenced", v)
+			}
+		}
+
+		for _, e := range sortedKeys(m.Environment) {
+			if !used.env[e] {
+				warn("", "environment variable %q is not referenced", e)
+			}
+		}
+	}
+
+	renderedKeys := make(map[string]string, len(keys))
+	orders := make(map[int]string, len(keys))
+	for _, k := range keys {
+		arg := m.Arguments[k]
+
+		if v, err := strconv.ParseBool(string(arg.SkipKey)); err != nil || !v {
+			key := arg.Key
+			if key == "" {
+				key = k
+			}
+
+			if other, ok := renderedKeys[key]; ok {
+				warn(k, "key %q collides with argument %q", key, other)
+			} else {
+				renderedKeys[key] = k
+			}
+		}
+
+		if arg.Order == 0 {
+			continue
+		}
+
+		if other, ok := orders[arg.Order]; ok {
+			warn(k, "order %d is also used by argument %q", arg.Order, other)
+		} else {
+			orders[arg.Order] = k
+		}
+	}
+
+	return result
+}
+
+// templates returns every field of the argument which
+// may contain templates or runtime macros
+func (a Argument) templates() []string {
+	result := make([]string, 0, len(a.Value)+4)
+	result = append(result, a.Value...)
+	result = append(result,
+		string(a.Condition),
+		string(a.Required),
+		string(a.RepeatKey),
+		string(a.SkipKey),
+	)
+
+	return result
+}
+
+// templateRefs collects the variables and environment
+// entries referenced by templates and runtime macros
+type templateRefs struct {
+	vars map[string]bool
+	env  map[string]bool
+	// dynamic is set if the variables are accessed
+	// in a way which can not be determined statically
+	dynamic bool
+	// macros enables the scanning for runtime macros
+	macros bool
+}
+
+func newTemplateRefs(macros bool) *templateRefs {
+	result := &templateRefs{
+		vars:   make(map[string]bool),
+		env:    make(map[string]bool),
+		macros: macros,
+	}
+
+	return result
+}
+
+func (r *templateRefs) merge(o *templateRefs) {
+	for k := range o.vars {
+		r.vars[k] = true
+	}
+
+	for k := range o.env {
+		r.env[k] = true
+	}
+
+	r.dynamic = r.dynamic || o.dynamic
+}
+
+func (r *templateRefs) sortedVars() []string {
+	return sortedKeys(r.vars)
+}
+
+func (r *templateRefs) sortedEnv() []string {
+	return sortedKeys(r.env)
+}
+
+// add records the references of the given template. Strings without
+// template syntax are scanned for runtime macros instead, if enabled.
+// Unparsable templates are ignored, they are reported by the config check.
+func (r *templateRefs) add(s string) {
+	if !strings.Contains(s, template.TemplateToken) {
+		if !r.macros {
+			return
+		}
+
+		for _, name := range MacroNames(s) {
+			r.vars[name] = true
+		}
+
+		return
+	}
+
+	trees, err := parse.Parse("lint", s, "", "", map[string]interface{}(template.Functions), templateBuiltins)
+	if err != nil {
+		return
+	}
+
+	for _, tree := range trees {
+		r.walk(tree.Root, true)
+	}
+}
+
+// walk visits the given node and its children. root reports whether
+// the dot refers to the template context, which is not the case within
+// the body of range and with actions.
+func (r *templateRefs) walk(node parse.Node, root bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Nodes {
+			r.walk(c, root)
+		}
+	case *parse.ActionNode:
+		r.walk(n.Pipe, root)
+	case *parse.IfNode:
+		r.walk(n.Pipe, root)
+		r.walk(n.List, root)
+		r.walk(n.ElseList, root)
+	case *parse.RangeNode:
+		r.walk(n.Pipe, root)
+		r.walk(n.List, false)
+		r.walk(n.ElseList, root)
+	case *parse.WithNode:
+		r.walk(n.Pipe, root)
+		r.walk(n.List, false)
+		r.walk(n.ElseList, root)
+	case *parse.TemplateNode:
+		r.walk(n.Pipe, root)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Cmds {
+			r.walk(c, root)
+		}
+	case *parse.CommandNode:
+		if r.index(n, root) {
+			return
+		}
+
+		for _, c := range n.Args {
+			r.walk(c, root)
+		}
+	case *parse.ChainNode:
+		r.walk(n.Node, root)
+	case *parse.FieldNode:
+		if root {
+			r.field(n.Ident)
+		}
+	case *parse.VariableNode:
+		// $ always refers to the template context
+		if len(n.Ident) > 0 && n.Ident[0] == "$" {
+			r.field(n.Ident[1:])
+		}
+	}
+}
+
+// field records a reference using the identifiers of a field chain
+func (r *templateRefs) field(ident []string) {
+	if len(ident) == 0 {
+		return
+	}
+
+	switch ident[0] {
+	case "Vars":
+		if len(ident) > 1 {
+			r.vars[ident[1]] = true
+		} else {
+			r.dynamic = true
+		}
+	case "Env":
+		if len(ident) > 1 {
+			r.env[ident[1]] = true
+		} else {
+			r.dynamic = true
+		}
+	}
+}
+
+// index records references using the index function with
+// a constant key (e.g. index .Vars "name"); the result
+// reports whether the command has been handled.
+func (r *templateRefs) index(n *parse.CommandNode, root bool) bool {
+	if len(n.Args) != 3 {
+		return false
+	}
+
+	fn, ok := n.Args[0].(*parse.IdentifierNode)
+	if !ok || fn.Ident != "index" {
+		return false
+	}
+
+	key, ok := n.Args[2].(*parse.StringNode)
+	if !ok {
+		return false
+	}
+
+	var ident []string
+	switch x := n.Args[1].(type) {
+	case *parse.FieldNode:
+		if !root {
+			return false
+		}
+
+		ident = x.Ident
+	case *parse.VariableNode:
+		if len(x.Ident) == 0 || x.Ident[0] != "$" {
+			return false
+		}
+
+		ident = x.Ident[1:]
+	default:
+		return false
+	}
+
+	if len(ident) != 1 {
+		return false
+	}
+
+	r.field(append(ident, key.Text))
+
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTemplateRefs(t *testing.T) {
+	type testCase struct {
+		have        string
+		wantVars    []string
+		wantEnv     []string
+		wantDynamic bool
+	}
+
+	testCases := map[string]testCase{
+		"fields": testCase{
+			have:     `{{ .Vars.host | first }}:{{ .Vars.port | join "," }}`,
+			wantVars: []string{"host", "port"},
+			wantEnv:  []string{},
+		},
+		"conditions": testCase{
+			have:     `{{ if and .Vars.ssl (not .Vars.plain) }}{{ .Env.HOME }}{{ end }}`,
+			wantVars: []string{"plain", "ssl"},
+			wantEnv:  []string{"HOME"},
+		},
+		"index": testCase{
+			have:     `{{ index .Vars "my-var" | first }}`,
+			wantVars: []string{"my-var"},
+			wantEnv:  []string{},
+		},
+		"range": testCase{
+			have:     `{{ range .Vars.codes }}-e {{ . }} {{ $.Vars.sep }}{{ .Vars.ignored }}{{ end }}`,
+			wantVars: []string{"codes", "sep"},
+			wantEnv:  []string{},
+		},
+		"dynamic": testCase{
+			have:        `{{ range $k, $v := .Vars }}{{ $k }}{{ end }}`,
+			wantVars:    []string{},
+			wantEnv:     []string{},
+			wantDynamic: true,
+		},
+		"macros": testCase{
+			have:     "$address$:$service.vars.port$",
+			wantVars: []string{"address", "port"},
+			wantEnv:  []string{},
+		},
+		"broken": testCase{
+			have:     "{{ .Vars.host",
+			wantVars: []string{},
+			wantEnv:  []string{},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got := newTemplateRefs(true)
+			got.add(tc.have)
+
+			assert.DeepEqual(t, tc.wantVars, got.sortedVars())
+			assert.DeepEqual(t, tc.wantEnv, got.sortedEnv())
+			assert.Equal(t, tc.wantDynamic, got.dynamic)
+		})
+	}
+}
+
+func TestModuleLint(t *testing.T) {
+	type testCase struct {
+		have Module
+		want []string
+	}
+
+	testCases := map[string]testCase{
+		"clean": testCase{
+			have: Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]Argument{
+					"-H":  Argument{Value: LazyArray{"{{ .Vars.host | first }}"}, Order: 1},
+					"-p":  Argument{Value: LazyArray{"$port$"}, Order: 2},
+					"URI": Argument{Value: LazyArray{"{{ .Env.URI }}"}, SkipKey: "true"},
+				},
+				Variables: map[string]Variable{
+					"host": Variable{Value: LazyArray{"localhost"}},
+					"port": Variable{Value: LazyArray{"80"}},
+				},
+				Environment: map[string]string{
+					"URI": "/",
+				},
+				Macros: true,
+			},
+		},
+		"literal macros": testCase{
+			have: Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]Argument{
+					"-a": Argument{Value: LazyArray{"user:pa$word$"}},
+				},
+				Variables: map[string]Variable{
+					"word": Variable{Value: LazyArray{"secret"}},
+				},
+			},
+			want: []string{
+				`module "test" argument "-a": runtime macro "$word$" is passed literally, as macros are not enabled`,
+				`module "test": variable "word" is not referenced`,
+			},
+		},
+		"undeclared": testCase{
+			have: Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]Argument{
+					"-H": Argument{Value: LazyArray{"{{ .Vars.hots | first }}"}},
+					"-S": Argument{Condition: "{{ if .Env.SSL }}true{{ end }}"},
+				},
+				NRPE: &NRPE{
+					Address: "{{ .Vars.address | first }}",
+				},
+			},
+			want: []string{
+				`module "test" argument "-H": variable "hots" is not declared`,
+				`module "test" argument "-S": environment variable "SSL" is not declared`,
+				`module "test": NRPE address: variable "address" is not declared`,
+			},
+		},
+		"unreferenced": testCase{
+			have: Module{
+				Command: "/bin/check_dummy",
+				Variables: map[string]Variable{
+					"state": Variable{Value: LazyArray{"0"}},
+				},
+				Environment: map[string]string{
+					"LANG": "C",
+				},
+			},
+			want: []string{
+				`module "test": variable "state" is not referenced`,
+				`module "test": environment variable "LANG" is not referenced`,
+			},
+		},
+		"collisions": testCase{
+			have: Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]Argument{
+					"-p":     Argument{Value: LazyArray{"80"}, Order: 1},
+					"--port": Argument{Key: "-p", Value: LazyArray{"8080"}, Order: 2},
+					"-u":     Argument{Value: LazyArray{"/"}, Order: 2},
+					"ARG1":   Argument{Value: LazyArray{"-p"}, SkipKey: "true", Order: 3},
+				},
+			},
+			want: []string{
+				`module "test" argument "-p": key "-p" collides with argument "--port"`,
+				`module "test" argument "-u": order 2 is also used by argument "--port"`,
+			},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			warnings := tc.have.Lint("test")
+
+			got := make([]string, len(warnings))
+			for i, w := range warnings {
+				got[i] = w.String()
+			}
+
+			if tc.want == nil {
+				tc.want = []string{}
+			}
+
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
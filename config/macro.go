@@ -0,0 +1,99 @@
+package config
+
+import (
+	"strings"
+)
+
+// MacroToken marks the beginning and end of Icinga style runtime macros
+const MacroToken = "$"
+
+// MacroPrefixes are stripped from runtime macro names,
+// as modules only know a single variable namespace
+var MacroPrefixes = []string{
+	"command.vars.",
+	"service.vars.",
+	"host.vars.",
+}
+
+// MacroVariable returns the name of the variable
+// referenced by the given runtime macro name
+func MacroVariable(name string) string {
+	for _, p := range MacroPrefixes {
+		name = strings.TrimPrefix(name, p)
+	}
+
+	return name
+}
+
+// IsMacroName reports whether the given string is a valid macro name.
+// This prevents the expansion of dollar signs in regular text.
+func IsMacroName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SplitMacros splits the given string into literal text and runtime macro
+// names ($name$). The result alternates between text and macro names,
+// starting and ending with text. $$ is unescaped to a literal dollar sign;
+// dollar signs which do not enclose a valid macro name are kept as text.
+func SplitMacros(s string) []string {
+	text := &strings.Builder{}
+	result := []string{}
+
+	for {
+		i := strings.Index(s, MacroToken)
+		if i < 0 {
+			text.WriteString(s)
+			break
+		}
+
+		text.WriteString(s[:i])
+		s = s[i+1:]
+
+		j := strings.Index(s, MacroToken)
+		if j < 0 {
+			text.WriteString(MacroToken)
+			text.WriteString(s)
+			break
+		} else if j == 0 {
+			text.WriteString(MacroToken)
+			s = s[1:]
+			continue
+		} else if !IsMacroName(s[:j]) {
+			// keep the leading dollar sign and reconsider
+			// the closing one as start of the next macro
+			text.WriteString(MacroToken)
+			text.WriteString(s[:j])
+			s = s[j:]
+			continue
+		}
+
+		result = append(result, text.String(), s[:j])
+		text.Reset()
+		s = s[j+1:]
+	}
+
+	return append(result, text.String())
+}
+
+// MacroNames returns the variable names of the
+// runtime macros ($name$) in the given string
+func MacroNames(s string) []string {
+	var result []string
+
+	parts := SplitMacros(s)
+	for i := 1; i < len(parts); i += 2 {
+		result = append(result, MacroVariable(parts[i]))
+	}
+
+	return result
+}
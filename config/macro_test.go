@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSplitMacros(t *testing.T) {
+	testCases := map[string][]string{
+		"":                     []string{""},
+		"plain":                []string{"plain"},
+		"$address$":            []string{"", "address", ""},
+		"http://$address$/":    []string{"http://", "address", "/"},
+		"$$HOME/$user$":        []string{"$HOME/", "user", ""},
+		"$5 and $address$":     []string{"$5 and ", "address", ""},
+		"$unterminated":        []string{"$unterminated"},
+		"$a$$b$":               []string{"", "a", "", "b", ""},
+		"price: 5$ (or 4$$)":   []string{"price: 5$ (or 4$)"},
+		"$host.vars.address$:": []string{"", "host.vars.address", ":"},
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.DeepEqual(t, want, SplitMacros(have))
+		})
+	}
+}
+
+func TestMacroNames(t *testing.T) {
+	testCases := map[string][]string{
+		"":                        nil,
+		"plain":                   nil,
+		"$address$":               []string{"address"},
+		"$host.vars.port$:$$":     []string{"port"},
+		"$5 or $6 and $address$":  []string{"address"},
+		"$$escaped$$ and $user$$": []string{"user"},
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.DeepEqual(t, want, MacroNames(have))
+		})
+	}
+}
@@ -4,15 +4,101 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ModuleTypeExec executes the module command as local process
+	ModuleTypeExec = "exec"
+	// ModuleTypeNRPE sends the module command to a remote NRPE daemon
+	ModuleTypeNRPE = "nrpe"
 )
 
 // Module defines a reusable monitoring execution plan
 type Module struct {
-	Command     string               `yaml:"command,omitempty"`
-	Timeout     NumberDuration       `yaml:"timeout,omitempty"`
-	Arguments   map[string]Argument  `yaml:"arguments,omitempty"`
-	Variables   map[string]LazyArray `yaml:"variables,omitempty"`
-	Environment map[string]string    `yaml:"environment,omitempty"`
+	// Imports lists modules whose settings are inherited. Later imports
+	// take precedence over earlier ones, the module itself over all of them.
+	Imports []string `yaml:"import,omitempty" json:"import,omitempty"`
+	// RemoveArguments lists inherited arguments to drop
+	RemoveArguments []string `yaml:"remove_arguments,omitempty" json:"remove_arguments,omitempty"`
+
+	Type        string              `yaml:"type,omitempty" json:"type,omitempty"`
+	Command     string              `yaml:"command,omitempty" json:"command,omitempty"`
+	Timeout     NumberDuration      `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Arguments   map[string]Argument `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+	Variables   map[string]Variable `yaml:"variables,omitempty" json:"variables,omitempty"`
+	Environment map[string]string   `yaml:"environment,omitempty" json:"environment,omitempty"`
+	NRPE        *NRPE               `yaml:"nrpe,omitempty" json:"nrpe,omitempty"`
+	// NRPEArguments maps the positional arguments of NRPE queries
+	// ($ARG1$, $ARG2$, ...) to variables
+	NRPEArguments []string `yaml:"nrpe_arguments,omitempty" json:"nrpe_arguments,omitempty"`
+	// Macros enables Icinga style runtime macros ($name$) in arguments;
+	// otherwise dollar signs are passed along literally
+	Macros bool `yaml:"macros,omitempty" json:"macros,omitempty"`
+}
+
+// UnmarshalYAML populates the instace fields from the
+// given data node
+func (m *Module) UnmarshalYAML(value *yaml.Node) error {
+	type rawModule Module
+	if err := decodeKnownFields(value, (*rawModule)(m)); err != nil {
+		return err
+	}
+
+	switch m.Type {
+	case "", ModuleTypeExec, ModuleTypeNRPE:
+	default:
+		return fmt.Errorf("Unsupported module type %q", m.Type)
+	}
+
+	if len(m.Imports) > 0 {
+		// settings might be inherited; validated once the imports are resolved
+		return nil
+	}
+
+	return m.validate()
+}
+
+// validate ensures the consistency of the module settings
+func (m *Module) validate() error {
+	if m.Type == ModuleTypeNRPE && m.NRPE == nil {
+		return fmt.Errorf("Module type %q requires the nrpe settings", m.Type)
+	}
+
+	for _, v := range m.NRPEArguments {
+		if variable, ok := m.Variables[v]; !ok {
+			return fmt.Errorf("NRPE argument %q is not a declared variable", v)
+		} else if !variable.IsOverridable() {
+			return fmt.Errorf("NRPE argument %q is not an overridable variable", v)
+		}
+	}
+
+	return nil
+}
+
+// readValueFiles reads the value files of the module variables,
+// resolving relative paths against the given directory. The
+// variables are replaced rather than modified, as they might
+// be shared with other configuration instances.
+func (m *Module) readValueFiles(dir string) error {
+	if m.Variables == nil {
+		return nil
+	}
+
+	variables := make(map[string]Variable, len(m.Variables))
+	for _, k := range sortedKeys(m.Variables) {
+		v := m.Variables[k]
+		if err := v.readValueFile(dir); err != nil {
+			return fmt.Errorf("variable %q: %s", k, err)
+		}
+
+		variables[k] = v
+	}
+	m.Variables = variables
+
+	return nil
 }
 
 type contextKey string
@@ -38,82 +124,104 @@ func FromContext(ctx context.Context) (string, *Module, bool) {
 // MarshalIcinga renders the module in the Icinga config syntax format
 func (m *Module) MarshalIcinga(name string) ([]byte, error) {
 	buf := &bytes.Buffer{}
+	body := &bytes.Buffer{}
 
-	buf.WriteString("object CheckCommand \"")
-	buf.WriteString(name)
-	buf.WriteString("\" {\n")
+	buf.WriteString("object CheckCommand ")
+	buf.WriteString(icingaQuote(name))
+	buf.WriteString(" {\n")
 
-	m.marshalIcingaCommand(buf)
-	m.marshalIcingaTimeout(buf)
-	m.marshalIcingaEnvironment(buf)
+	m.marshalIcingaCommand(body)
+	m.marshalIcingaTimeout(body)
+	m.marshalIcingaEnvironment(body)
 
-	if err := m.marshalIcingaArguments(buf); err != nil {
+	if err := m.marshalIcingaArguments(body); err != nil {
 		return nil, err
 	}
 
-	if err := m.marshalIcingaVars(buf); err != nil {
+	if err := m.marshalIcingaVars(body); err != nil {
 		return nil, err
 	}
 
-	buf.WriteByte('}')
+	icingaIndent(buf, body.Bytes(), "  ")
+	buf.WriteString("}\n")
 
 	return buf.Bytes(), nil
 }
 
 func (m *Module) marshalIcingaEnvironment(buf *bytes.Buffer) {
-	buf.WriteString("  env = {\n")
+	if len(m.Environment) == 0 {
+		return
+	}
 
-	for k, v := range m.Environment {
-		buf.WriteString("    \"")
-		buf.WriteString(k)
-		buf.WriteString("\" = \"")
-		buf.WriteString(v)
-		buf.WriteString("\"\n")
+	keys := make([]string, 0, len(m.Environment))
+	for k := range m.Environment {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	buf.WriteString("  }\n")
+	buf.WriteString("env = {\n")
+	for _, k := range keys {
+		buf.WriteString("  ")
+		icingaAssign(buf, icingaQuote(k), icingaLiteral(m.Environment[k]), "")
+	}
+	buf.WriteString("}\n")
 }
 
 func (m *Module) marshalIcingaArguments(buf *bytes.Buffer) error {
-	buf.WriteString("  arguments = {\n")
+	if len(m.Arguments) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m.Arguments))
+	for k := range m.Arguments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf.WriteString("arguments = {\n")
+	for _, k := range keys {
+		v := m.Arguments[k]
+		if !m.Macros {
+			v = v.literal()
+		}
 
-	for k, v := range m.Arguments {
 		a, err := v.MarshalIcinga(k)
 		if err != nil {
 			return err
 		}
 
-		buf.WriteString("    ")
-		buf.Write(a)
+		icingaIndent(buf, a, "  ")
 	}
-
-	buf.WriteString("  }\n")
+	buf.WriteString("}\n")
 
 	return nil
 }
 
 func (m *Module) marshalIcingaVars(buf *bytes.Buffer) error {
-	for k, v := range m.Variables {
-		i, err := v.MarshalIcinga(k)
+	keys := make([]string, 0, len(m.Variables))
+	for k := range m.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		i, err := m.Variables[k].Value.MarshalIcinga(icingaAttribute("vars", k))
 		if err != nil {
 			return err
 		}
 
-		buf.WriteString("  vars.")
 		buf.Write(i)
-		buf.WriteString("\n")
 	}
 
 	return nil
 }
 
 func (m *Module) marshalIcingaTimeout(buf *bytes.Buffer) {
-	buf.WriteString("  timeout = ")
-	fmt.Fprintf(buf, "%s\n", m.Timeout)
+	if m.Timeout != 0 {
+		icingaAssign(buf, "timeout", m.Timeout.String(), "")
+	}
 }
 
 func (m *Module) marshalIcingaCommand(buf *bytes.Buffer) {
-	buf.WriteString("  command = [ ")
-	fmt.Fprintf(buf, "%q", m.Command)
-	buf.WriteString(" ]\n")
+	icingaAssign(buf, "command", "[ "+icingaQuote(m.Command)+" ]", "")
 }
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestModuleType(t *testing.T) {
+	type testCase struct {
+		wantError bool
+		want      *NRPE
+		have      []byte
+	}
+
+	testCases := map[string]testCase{
+		"implicit exec": testCase{
+			have: []byte("command: check_dummy"),
+		},
+		"explicit exec": testCase{
+			have: []byte("{type: exec, command: check_dummy}"),
+		},
+		"unknown type": testCase{
+			have:      []byte("{type: ssh, command: check_dummy}"),
+			wantError: true,
+		},
+		"nrpe without settings": testCase{
+			have:      []byte("{type: nrpe, command: check_load}"),
+			wantError: true,
+		},
+		"nrpe settings inherited": testCase{
+			have: []byte("{type: nrpe, command: check_load, import: [remote]}"),
+		},
+		"nrpe without address": testCase{
+			have:      []byte("{type: nrpe, command: check_load, nrpe: {}}"),
+			wantError: true,
+		},
+		"nrpe unsupported version": testCase{
+			have:      []byte("{type: nrpe, command: check_load, nrpe: {address: localhost, version: 4}}"),
+			wantError: true,
+		},
+		"nrpe defaults": testCase{
+			have: []byte("{type: nrpe, command: check_load, nrpe: {address: localhost}}"),
+			want: &NRPE{Address: "localhost", Version: 3, TLS: true},
+		},
+		"nrpe argument not overridable": testCase{
+			have:      []byte("{type: nrpe, command: check_load, nrpe: {address: localhost}, nrpe_arguments: [warn], variables: {warn: {value: 5, overridable: false}}}"),
+			wantError: true,
+		},
+		"unknown module field": testCase{
+			have:      []byte("{comand: check_dummy}"),
+			wantError: true,
+		},
+		"unknown nrpe field": testCase{
+			have:      []byte("{type: nrpe, command: check_load, nrpe: {address: localhost, verison: 2}}"),
+			wantError: true,
+		},
+		"unknown variable field": testCase{
+			have:      []byte("{command: check_dummy, variables: {warn: {value: 5, overridabel: false}}}"),
+			wantError: true,
+		},
+		"nrpe plaintext v2": testCase{
+			have: []byte("{type: nrpe, command: check_load, nrpe: {address: localhost, version: 2, tls: false}}"),
+			want: &NRPE{Address: "localhost", Version: 2, TLS: false},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			var subject Module
+			err := yaml.Unmarshal(tc.have, &subject)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+			} else {
+				assert.NilError(t, err)
+				assert.DeepEqual(t, tc.want, subject.NRPE)
+			}
+		})
+	}
+}
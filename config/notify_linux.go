@@ -0,0 +1,118 @@
+//go:build linux
+
+package config
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// inotify is a notifier backed by the Linux inotify API. The events
+// themselves are discarded, as only their occurrence is relevant.
+type inotify struct {
+	file    *os.File
+	watches map[string]int
+	events  chan struct{}
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	result := &inotify{
+		// non-blocking descriptors are handled by the runtime poller,
+		// which allows Close to interrupt pending reads
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[string]int),
+		events:  make(chan struct{}, 1),
+	}
+
+	go result.read()
+
+	return result, nil
+}
+
+func (n *inotify) read() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			close(n.events)
+			return
+		}
+
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotify) Watch(dirs []string) error {
+	keep := make(map[string]bool, len(dirs))
+
+	for _, dir := range dirs {
+		keep[dir] = true
+		if _, ok := n.watches[dir]; ok {
+			continue
+		}
+
+		var wd int
+		err := n.control(func(fd int) (err error) {
+			wd, err = unix.InotifyAddWatch(fd, dir, inotifyMask)
+			return
+		})
+		if err == unix.ENOENT {
+			// retried with the next set of directories
+			continue
+		} else if err != nil {
+			return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+		}
+
+		n.watches[dir] = wd
+	}
+
+	for dir, wd := range n.watches {
+		if !keep[dir] {
+			// the watch might be gone already along with the directory
+			n.control(func(fd int) error {
+				_, err := unix.InotifyRmWatch(fd, uint32(wd))
+				return err
+			})
+			delete(n.watches, dir)
+		}
+	}
+
+	return nil
+}
+
+// control calls fn with the inotify descriptor. Unlike File.Fd,
+// this keeps the descriptor in non-blocking mode.
+func (n *inotify) control(fn func(fd int) error) error {
+	conn, err := n.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+func (n *inotify) Close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package config
+
+import (
+	"errors"
+)
+
+func newNotifier() (notifier, error) {
+	return nil, errors.New("file system notifications are not supported on this platform")
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+
+	promconfig "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v3"
+)
+
+// NRPE defines the connection details of a remote NRPE daemon
+type NRPE struct {
+	Address   string               `yaml:"address,omitempty" json:"address,omitempty"`
+	Version   int                  `yaml:"version,omitempty" json:"version,omitempty"`
+	TLS       bool                 `yaml:"tls" json:"tls"`
+	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty" json:"tls_config,omitempty"`
+}
+
+// UnmarshalYAML populates the instace fields from the
+// given data node
+func (n *NRPE) UnmarshalYAML(value *yaml.Node) error {
+	n.Version = 3
+	n.TLS = true
+
+	type rawNRPE NRPE
+	if err := decodeKnownFields(value, (*rawNRPE)(n)); err != nil {
+		return err
+	}
+
+	if n.Address == "" {
+		return fmt.Errorf("NRPE address must not be empty")
+	}
+
+	if n.Version != 2 && n.Version != 3 {
+		return fmt.Errorf("Unsupported NRPE packet version %d", n.Version)
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+var (
+	// ErrInvalidModule is returned when a module submitted
+	// at runtime does not pass the configuration checks
+	ErrInvalidModule = errors.New("invalid module")
+	// ErrUnknownModule is returned when removing
+	// a module which is not configured
+	ErrUnknownModule = errors.New("unknown module")
+)
+
+// Overlay holds the modules added, changed or removed at runtime.
+// It is applied on top of the modules loaded from the configuration
+// files. Instances are never modified once they are in use.
+type Overlay struct {
+	// Modules replace or complement the modules of the configuration files
+	Modules map[string]Module `yaml:"modules,omitempty" json:"modules,omitempty"`
+	// Removed lists the modules of the configuration files to drop
+	Removed []string `yaml:"removed,omitempty" json:"removed,omitempty"`
+}
+
+// ModuleChecker validates the given module beyond its settings,
+// e.g. by rendering its templates, and returns all problems found
+type ModuleChecker func(name string, module *Module) []error
+
+// LoadOverlay reads an overlay file. A missing file results
+// in an empty overlay, as it is created on the first change.
+func LoadOverlay(file string) (*Overlay, error) {
+	result := &Overlay{}
+
+	r, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading overlay file: %s", err)
+	}
+	defer r.Close()
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(result); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing overlay file %s: %s", file, err)
+	}
+
+	for name, module := range result.Modules {
+		if err := module.readValueFiles(filepath.Dir(file)); err != nil {
+			return nil, fmt.Errorf("error loading overlay file %s: module %q: %s", file, name, err)
+		}
+
+		result.Modules[name] = module
+	}
+
+	return result, nil
+}
+
+// put returns a copy of the overlay containing the given module
+func (o *Overlay) put(name string, module Module) *Overlay {
+	result := &Overlay{
+		Modules: make(map[string]Module, len(o.Modules)+1),
+	}
+	for k, v := range o.Modules {
+		result.Modules[k] = v
+	}
+	result.Modules[name] = module
+
+	for _, removed := range o.Removed {
+		if removed != name {
+			result.Removed = append(result.Removed, removed)
+		}
+	}
+
+	return result
+}
+
+// delete returns a copy of the overlay without the given module.
+// Modules of the configuration files are recorded as removed.
+func (o *Overlay) delete(name string, source *Config) *Overlay {
+	result := &Overlay{
+		Removed: append([]string(nil), o.Removed...),
+	}
+	for k, v := range o.Modules {
+		if k == name {
+			continue
+		}
+
+		if result.Modules == nil {
+			result.Modules = make(map[string]Module, len(o.Modules))
+		}
+		result.Modules[k] = v
+	}
+
+	if _, ok := source.Modules[name]; ok {
+		result.Removed = append(result.Removed, name)
+	}
+
+	return result
+}
+
+// apply merges the overlay into a copy of the given configuration
+// and resolves the module imports of the result. The value files
+// of the overlay modules are read again to pick up changes.
+func (o *Overlay) apply(source *Config) (*Config, error) {
+	result := &Config{
+		Include: source.Include,
+		Modules: make(map[string]Module, len(source.Modules)+len(o.Modules)),
+		files:   source.files,
+	}
+	for name, module := range source.Modules {
+		result.Modules[name] = module
+	}
+	for _, name := range o.Removed {
+		delete(result.Modules, name)
+	}
+	for name, module := range o.Modules {
+		if err := module.readValueFiles(""); err != nil {
+			return nil, fmt.Errorf("module %q: %s", name, err)
+		}
+
+		result.Modules[name] = module
+	}
+
+	if err := result.resolveImports(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// save writes the overlay to the given file, replacing it atomically
+func (o *Overlay) save(file string) error {
+	data, err := yaml.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// PutModule adds or replaces the given module at runtime. The resulting
+// configuration is checked like one loaded from the configuration files
+// and activated atomically. Errors caused by the module wrap
+// ErrInvalidModule.
+func (sc *SafeConfig) PutModule(name string, module Module, logger log.Logger) (*Reload, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: module name is missing", ErrInvalidModule)
+	}
+
+	for _, k := range sortedKeys(module.Variables) {
+		// there is no file to resolve relative paths against
+		if v := module.Variables[k]; v.ValueFile != "" && !filepath.IsAbs(v.ValueFile) {
+			return nil, fmt.Errorf("%w: module %q: variable %q: value_file must be an absolute path", ErrInvalidModule, name, k)
+		}
+	}
+
+	if len(module.Imports) == 0 {
+		// modules with imports are validated once merged
+		if err := module.validate(); err != nil {
+			return nil, fmt.Errorf("%w: module %q: %s", ErrInvalidModule, name, err)
+		}
+	}
+
+	sc.update.Lock()
+	defer sc.update.Unlock()
+
+	return sc.applyOverlay(sc.overlay.put(name, module), logger)
+}
+
+// DeleteModule removes the given module at runtime. The returned
+// error wraps ErrUnknownModule if there is no such module and
+// ErrInvalidModule if other modules still import it.
+func (sc *SafeConfig) DeleteModule(name string, logger log.Logger) (*Reload, error) {
+	sc.update.Lock()
+	defer sc.update.Unlock()
+
+	if _, ok := sc.Snapshot().Config.Modules[name]; !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownModule, name)
+	}
+
+	return sc.applyOverlay(sc.overlay.delete(name, sc.source), logger)
+}
+
+// SetOverlayFile loads the runtime module changes from the given
+// file, which is updated on every change. Changes are kept in
+// memory only if no file is set.
+func (sc *SafeConfig) SetOverlayFile(file string) error {
+	overlay, err := LoadOverlay(file)
+	if err != nil {
+		return err
+	}
+
+	sc.update.Lock()
+	sc.overlay = overlay
+	sc.overlayFile = file
+	sc.update.Unlock()
+
+	return nil
+}
+
+// applyOverlay checks and activates the configuration files merged with
+// the given overlay, persisting the overlay on success. The caller must
+// hold the update lock.
+func (sc *SafeConfig) applyOverlay(overlay *Overlay, logger log.Logger) (*Reload, error) {
+	c, err := overlay.apply(sc.source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidModule, err)
+	}
+
+	for _, w := range c.Lint() {
+		level.Warn(logger).Log("msg", "Questionable module configuration", "module", w.Module, "argument", w.Argument, "warning", w.Message)
+	}
+
+	if err := sc.check(c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidModule, err)
+	}
+
+	failures, err := sc.preflight(c, logger)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidModule, err)
+	}
+
+	if sc.overlayFile != "" {
+		if err := overlay.save(sc.overlayFile); err != nil {
+			return nil, fmt.Errorf("unable to save overlay file: %s", err)
+		}
+	}
+
+	sc.overlay = overlay
+
+	return sc.commit(c, failures, 0, logger), nil
+}
+
+// check runs the module checker for all modules
+// which differ from the active configuration
+func (sc *SafeConfig) check(c *Config) error {
+	sc.RLock()
+	checker := sc.checker
+	sc.RUnlock()
+
+	if checker == nil {
+		return nil
+	}
+
+	diff := DiffConfig(sc.Snapshot().Config, c)
+	names := append([]string{}, diff.Added...)
+	names = append(names, sortedKeys(diff.Changed)...)
+	sort.Strings(names)
+
+	var errs []string
+	for _, name := range names {
+		module := c.Modules[name]
+		for _, err := range checker(name, &module) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
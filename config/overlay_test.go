@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gotest.tools/v3/assert"
+)
+
+func TestSafeConfigPutModule(t *testing.T) {
+	type testCase struct {
+		name       string
+		have       Module
+		wantModule Module
+		wantError  string
+	}
+
+	valueFile := filepath.Join(writeConfigFiles(t, map[string]string{"community": "public\n"}), "community")
+
+	testCases := map[string]testCase{
+		"add": testCase{
+			name:       "ping",
+			have:       Module{Command: "/bin/check_ping"},
+			wantModule: Module{Command: "/bin/check_ping"},
+		},
+		"replace": testCase{
+			name:       "http",
+			have:       Module{Command: "/usr/bin/check_http"},
+			wantModule: Module{Command: "/usr/bin/check_http"},
+		},
+		"import": testCase{
+			name: "https",
+			have: Module{Imports: []string{"http"}, Environment: map[string]string{"LANG": "C"}},
+			wantModule: Module{
+				Imports:     []string{"http"},
+				Command:     "/bin/check_http",
+				Environment: map[string]string{"LANG": "C"},
+			},
+		},
+		"invalid": testCase{
+			name:      "nrpe",
+			have:      Module{Type: ModuleTypeNRPE, Command: "check_load"},
+			wantError: `invalid module: module "nrpe": Module type "nrpe" requires the nrpe settings`,
+		},
+		"unknown import": testCase{
+			name:      "https",
+			have:      Module{Imports: []string{"htp"}},
+			wantError: `invalid module: module "https" imports unknown module "htp"`,
+		},
+		"failed check": testCase{
+			name:      "ping",
+			have:      Module{Command: "/bin/check_ping", Arguments: map[string]Argument{"-H": Argument{Value: LazyArray{"{{ .Vars.host"}}}},
+			wantError: `invalid module: module "ping": broken template`,
+		},
+		"value file": testCase{
+			name:       "snmp",
+			have:       Module{Command: "/bin/check_snmp", Variables: map[string]Variable{"community": Variable{ValueFile: valueFile}}},
+			wantModule: Module{Command: "/bin/check_snmp", Variables: map[string]Variable{"community": Variable{Value: LazyArray{"public"}, ValueFile: valueFile}}},
+		},
+		"relative value file": testCase{
+			name:      "snmp",
+			have:      Module{Command: "/bin/check_snmp", Variables: map[string]Variable{"community": Variable{ValueFile: "community"}}},
+			wantError: `invalid module: module "snmp": variable "community": value_file must be an absolute path`,
+		},
+		"missing value file": testCase{
+			name:      "snmp",
+			have:      Module{Command: "/bin/check_snmp", Variables: map[string]Variable{"community": Variable{ValueFile: valueFile + ".missing"}}},
+			wantError: `invalid module: module "snmp": variable "community": Unable to read variable value file: open ` + valueFile + `.missing: no such file or directory`,
+		},
+		"missing name": testCase{
+			have:      Module{Command: "/bin/true"},
+			wantError: "invalid module: module name is missing",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			dir := writeConfigFiles(t, map[string]string{
+				"config.yml": "modules:\n  http:\n    command: /bin/check_http\n",
+			})
+			subject := NewSafeConfig("test", prometheus.NewRegistry())
+			subject.SetModuleChecker(func(name string, module *Module) []error {
+				if len(module.Arguments) > 0 {
+					return []error{fmt.Errorf("module %q: broken template", name)}
+				}
+
+				return nil
+			})
+			_, err := subject.ReloadConfig(filepath.Join(dir, "config.yml"), log.NewNopLogger())
+			assert.NilError(t, err)
+
+			got, err := subject.PutModule(tc.name, tc.have, log.NewNopLogger())
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				assert.Assert(t, errors.Is(err, ErrInvalidModule))
+				assert.Equal(t, 1, subject.Snapshot().Generation)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, 2, got.Generation)
+			assert.DeepEqual(t, tc.wantModule, subject.Snapshot().Config.Modules[tc.name])
+		})
+	}
+}
+
+func TestSafeConfigDeleteModule(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yml": "modules:\n  http:\n    command: /bin/check_http\n  https:\n    import: [http]\n",
+	})
+	subject := NewSafeConfig("test", prometheus.NewRegistry())
+	_, err := subject.ReloadConfig(filepath.Join(dir, "config.yml"), log.NewNopLogger())
+	assert.NilError(t, err)
+
+	_, err = subject.DeleteModule("ping", log.NewNopLogger())
+	assert.Assert(t, errors.Is(err, ErrUnknownModule))
+
+	_, err = subject.DeleteModule("http", log.NewNopLogger())
+	assert.Assert(t, errors.Is(err, ErrInvalidModule))
+
+	got, err := subject.DeleteModule("https", log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"https"}, got.Diff.Removed)
+
+	// modules removed at runtime stay removed after a reload
+	_, err = subject.ReloadConfig(filepath.Join(dir, "config.yml"), log.NewNopLogger())
+	assert.NilError(t, err)
+	_, ok := subject.Snapshot().Config.Modules["https"]
+	assert.Assert(t, !ok)
+}
+
+func TestSafeConfigOverlayFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yml": "modules:\n  http:\n    command: /bin/check_http\n",
+	})
+	file := filepath.Join(dir, "config.yml")
+	overlay := filepath.Join(dir, "overlay.yml")
+
+	subject := NewSafeConfig("test", prometheus.NewRegistry())
+	assert.NilError(t, subject.SetOverlayFile(overlay))
+	_, err := subject.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+
+	_, err = subject.PutModule("ping", Module{Command: "/bin/check_ping"}, log.NewNopLogger())
+	assert.NilError(t, err)
+	_, err = subject.DeleteModule("http", log.NewNopLogger())
+	assert.NilError(t, err)
+
+	restarted := NewSafeConfig("test", prometheus.NewRegistry())
+	assert.NilError(t, restarted.SetOverlayFile(overlay))
+	_, err = restarted.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"ping"}, sortedKeys(restarted.Snapshot().Config.Modules))
+
+	got, err := LoadOverlay(overlay)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Overlay{
+		Modules: map[string]Module{"ping": Module{Command: "/bin/check_ping"}},
+		Removed: []string{"http"},
+	}, got)
+}
+
+func TestSafeConfigRollbackOverlay(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yml": "modules:\n  http:\n    command: /bin/check_http\n",
+	})
+	file := filepath.Join(dir, "config.yml")
+	overlay := filepath.Join(dir, "overlay.yml")
+
+	subject := NewSafeConfig("test", prometheus.NewRegistry())
+	assert.NilError(t, subject.SetOverlayFile(overlay))
+	_, err := subject.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+
+	_, err = subject.PutModule("ping", Module{Command: "/bin/check_ping"}, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(file, []byte("modules:\n  disk:\n    command: /bin/check_disk\n"), 0644))
+	_, err = subject.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"disk", "ping"}, sortedKeys(subject.Snapshot().Config.Modules))
+
+	_, err = subject.Rollback(1, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"http"}, sortedKeys(subject.Snapshot().Config.Modules))
+
+	// runtime changes build upon the restored configuration
+	got, err := subject.PutModule("load", Module{Command: "/bin/check_load"}, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"load"}, got.Diff.Added)
+	assert.DeepEqual(t, []string{"http", "load"}, sortedKeys(subject.Snapshot().Config.Modules))
+
+	_, err = subject.Rollback(1, log.NewNopLogger())
+	assert.NilError(t, err)
+	_, err = subject.DeleteModule("disk", log.NewNopLogger())
+	assert.Assert(t, errors.Is(err, ErrUnknownModule))
+
+	// the configuration files are loaded again, without
+	// the runtime changes undone by the rollback
+	_, err = subject.ReloadConfig(file, log.NewNopLogger())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"disk"}, sortedKeys(subject.Snapshot().Config.Modules))
+
+	saved, err := LoadOverlay(overlay)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Overlay{}, saved)
+}
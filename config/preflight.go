@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// Preflight runs nagios.Preflight for the plugin command of every
+// exec module. NRPE modules are executed remotely and are skipped.
+// The result maps module names to the encountered problem.
+func (c *Config) Preflight() map[string]error {
+	result := make(map[string]error)
+	for name, module := range c.Modules {
+		if module.Type == ModuleTypeNRPE || module.Command == "" {
+			continue
+		}
+
+		if err := nagios.Preflight(module.Command); err != nil {
+			result[name] = err
+		}
+	}
+
+	return result
+}
+
+// preflight checks the given config and logs the failures. In strict mode
+// the config is rejected if any module fails the checks.
+func (sc *SafeConfig) preflight(c *Config, logger log.Logger) (map[string]error, error) {
+	sc.RLock()
+	strict := sc.strict
+	sc.RUnlock()
+
+	failures := c.Preflight()
+
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		level.Warn(logger).Log("msg", "Module failed preflight check", "module", name, "err", failures[name])
+	}
+
+	if strict && len(names) > 0 {
+		return nil, fmt.Errorf("%d module(s) failed the preflight checks: %v", len(names), names)
+	}
+
+	return failures, nil
+}
+
+// recordReady updates the module ready gauge using the preflight
+// failures of the given config, which is about to be activated
+func (sc *SafeConfig) recordReady(c *Config, failures map[string]error) {
+	sc.moduleReady.Reset()
+	for name := range c.Modules {
+		if _, ok := failures[name]; ok {
+			sc.moduleReady.WithLabelValues(name).Set(0)
+		} else {
+			sc.moduleReady.WithLabelValues(name).Set(1)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
+)
+
+// SecretToken replaces redacted values
+const SecretToken = "<secret>"
+
+var (
+	// credentialPattern matches whole name segments, so that
+	// e.g. passive, author or tokenizer are not mistaken
+	credentialPattern = regexp.MustCompile(`(?i)(^|[_.-])((auth|priv)?pass(word|wd|phrase)?|secrets?|tokens?|credentials?|community|auth|api[_.-]?key|private[_.-]?key)($|[_.-])`)
+	camelCasePattern  = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	macroPattern      = regexp.MustCompile(`^\$[A-Za-z0-9_.]+\$$`)
+)
+
+// IsCredential reports whether the given variable, environment
+// or argument name is likely to hold sensitive information.
+// Names are split into segments at underscores, dashes, dots
+// and camel case boundaries.
+func IsCredential(name string) bool {
+	return credentialPattern.MatchString(camelCasePattern.ReplaceAllString(name, "${1}_${2}"))
+}
+
+// redactValue replaces the given value with the SecretToken, unless
+// it is empty or only references another value (template or macro)
+func redactValue(s string) string {
+	if s == "" || strings.Contains(s, template.TemplateToken) || macroPattern.MatchString(s) {
+		return s
+	}
+
+	return SecretToken
+}
+
+// Redacted creates a copy of the instance with
+// credential-like values in all modules redacted
+func (c *Config) Redacted() *Config {
+	result := &Config{
+		Modules: make(map[string]Module, len(c.Modules)),
+	}
+
+	for name, module := range c.Modules {
+		result.Modules[name] = module.Redacted()
+	}
+
+	return result
+}
+
+// Redacted creates a copy of the instance with secret and credential-like
+// variables, environment variables and argument values redacted.
+func (m Module) Redacted() Module {
+	if m.Arguments != nil {
+		arguments := make(map[string]Argument, len(m.Arguments))
+		for k, v := range m.Arguments {
+			if IsCredential(k) || IsCredential(v.Key) {
+				value := make(LazyArray, len(v.Value))
+				for i, s := range v.Value {
+					value[i] = redactValue(s)
+				}
+				v.Value = value
+			}
+
+			arguments[k] = v
+		}
+		m.Arguments = arguments
+	}
+
+	if m.Variables != nil {
+		variables := make(map[string]Variable, len(m.Variables))
+		for k, v := range m.Variables {
+			if v.Secret {
+				value := make(LazyArray, len(v.Value))
+				for i, s := range v.Value {
+					if s != "" {
+						value[i] = SecretToken
+					}
+				}
+				v.Value = value
+			} else if IsCredential(k) {
+				value := make(LazyArray, len(v.Value))
+				for i, s := range v.Value {
+					value[i] = redactValue(s)
+				}
+				v.Value = value
+			}
+
+			variables[k] = v
+		}
+		m.Variables = variables
+	}
+
+	if m.Environment != nil {
+		environment := make(map[string]string, len(m.Environment))
+		for k, v := range m.Environment {
+			if IsCredential(k) {
+				v = redactValue(v)
+			}
+
+			environment[k] = v
+		}
+		m.Environment = environment
+	}
+
+	return m
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIsCredential(t *testing.T) {
+	testCases := map[string]bool{
+		"address":        false,
+		"http_vhost":     false,
+		"-P":             false,
+		"password":       true,
+		"--authpassword": true,
+		"snmp_community": true,
+		"API_KEY":        true,
+		"MYSQL_PWD":      false,
+		"db_passwd":      true,
+		"bearer_token":   true,
+		"--privpasswd":   true,
+		"snmp_auth":      true,
+		"dbPassword":     true,
+		"apiKey":         true,
+		"private-key":    true,
+		"passive":        false,
+		"bypass_proxy":   false,
+		"compass":        false,
+		"author":         false,
+		"oauth_scope":    false,
+		"tokenizer":      false,
+		"secretary":      false,
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.Equal(t, want, IsCredential(have))
+		})
+	}
+}
+
+func TestModuleRedacted(t *testing.T) {
+	have := Module{
+		Command: "/usr/lib/nagios/plugins/check_snmp",
+		Arguments: map[string]Argument{
+			"--community": Argument{Value: LazyArray{"public"}},
+			"--password":  Argument{Value: LazyArray{"{{ .Vars.password | first }}"}},
+			"-a":          Argument{Key: "--authpassword", Value: LazyArray{"$snmp_auth$"}},
+			"-H":          Argument{Value: LazyArray{"localhost"}},
+		},
+		Variables: map[string]Variable{
+			"password": Variable{Value: LazyArray{"hunter2", ""}},
+			"address":  Variable{Value: LazyArray{"localhost"}},
+			"privacy":  Variable{Value: LazyArray{"{{ sesame }}"}, Secret: true},
+		},
+		Environment: map[string]string{
+			"API_TOKEN": "abc",
+			"LANG":      "C",
+		},
+	}
+	want := Module{
+		Command: "/usr/lib/nagios/plugins/check_snmp",
+		Arguments: map[string]Argument{
+			"--community": Argument{Value: LazyArray{SecretToken}},
+			"--password":  Argument{Value: LazyArray{"{{ .Vars.password | first }}"}},
+			"-a":          Argument{Key: "--authpassword", Value: LazyArray{"$snmp_auth$"}},
+			"-H":          Argument{Value: LazyArray{"localhost"}},
+		},
+		Variables: map[string]Variable{
+			"password": Variable{Value: LazyArray{SecretToken, ""}},
+			"address":  Variable{Value: LazyArray{"localhost"}},
+			"privacy":  Variable{Value: LazyArray{SecretToken}, Secret: true},
+		},
+		Environment: map[string]string{
+			"API_TOKEN": SecretToken,
+			"LANG":      "C",
+		},
+	}
+
+	got := have.Redacted()
+
+	assert.DeepEqual(t, want, got)
+	assert.Equal(t, "hunter2", have.Variables["password"].Value[0], "original must not be modified")
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	promconfig "github.com/prometheus/common/config"
+)
+
+// SchemaDialect is the JSON Schema draft used by JSONSchema
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+type schemaObject map[string]interface{}
+
+// schemaScalar matches every YAML scalar which can be decoded into a string
+var schemaScalar = []string{"string", "number", "boolean"}
+
+var (
+	lazyArrayType      = reflect.TypeOf(LazyArray{})
+	boolStringType     = reflect.TypeOf(BoolString(""))
+	numberDurationType = reflect.TypeOf(NumberDuration(0))
+	tlsVersionType     = reflect.TypeOf(promconfig.TLSVersion(0))
+)
+
+// schemaTypes are the definitions of types with custom unmarshal
+// logic, which accept values in various shapes
+var schemaTypes = map[reflect.Type]schemaObject{
+	lazyArrayType: schemaObject{
+		"description": "A single string or a list of strings",
+		"oneOf": []schemaObject{
+			schemaObject{"type": schemaScalar},
+			schemaObject{"type": "array", "items": schemaObject{"type": schemaScalar}},
+		},
+	},
+	boolStringType: schemaObject{
+		"description": "A boolean or a template rendering to a boolean",
+		"type":        []string{"boolean", "string"},
+	},
+	numberDurationType: schemaObject{
+		"description": "Number of seconds or a duration string",
+		"oneOf": []schemaObject{
+			schemaObject{"type": "integer", "minimum": 0},
+			// same syntax as accepted by time.ParseDuration
+			schemaObject{"type": "string", "pattern": `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$`},
+		},
+	},
+}
+
+// schemaOverrides amend the generated struct definitions
+// with constraints enforced by the unmarshal logic
+var schemaOverrides = map[string]func(schemaObject) schemaObject{
+	"Module": func(s schemaObject) schemaObject {
+		s["properties"].(schemaObject)["type"] = schemaObject{
+			"type": "string",
+			"enum": []string{ModuleTypeExec, ModuleTypeNRPE},
+		}
+		s["if"] = schemaObject{
+			"properties": schemaObject{"type": schemaObject{"const": ModuleTypeNRPE}},
+			"required":   []string{"type"},
+		}
+		s["then"] = schemaObject{
+			"required": []string{"nrpe"},
+		}
+
+		return s
+	},
+	"Argument": func(s schemaObject) schemaObject {
+		// arguments can be declared using their value only
+		return schemaObject{
+			"oneOf": []schemaObject{
+				schemaObject{"type": schemaScalar},
+				s,
+			},
+		}
+	},
+	"Variable": func(s schemaObject) schemaObject {
+		s["properties"].(schemaObject)["type"] = schemaObject{
+			"type": "string",
+			"enum": VariableTypes,
+		}
+		s["if"] = schemaObject{
+			"properties": schemaObject{"type": schemaObject{"const": VariableTypeEnum}},
+			"required":   []string{"type"},
+		}
+		s["then"] = schemaObject{
+			"required": []string{"allowed"},
+		}
+		// value and value_file are mutually exclusive
+		s["not"] = schemaObject{"required": []string{"value", "value_file"}}
+
+		// variables can be declared using their value only
+		return schemaObject{
+			"oneOf": []schemaObject{
+				schemaObject{"$ref": "#/$defs/LazyArray"},
+				s,
+			},
+		}
+	},
+	"NRPE": func(s schemaObject) schemaObject {
+		s["properties"].(schemaObject)["version"] = schemaObject{
+			"type": "integer",
+			"enum": []int{2, 3},
+		}
+		s["required"] = []string{"address"}
+
+		return s
+	},
+}
+
+type schemaGenerator struct {
+	defs schemaObject
+}
+
+// JSONSchema generates a JSON Schema describing the configuration file
+func JSONSchema() map[string]interface{} {
+	g := &schemaGenerator{
+		defs: schemaObject{},
+	}
+
+	result := g.typeSchema(reflect.TypeOf(Config{}))
+	result["$schema"] = SchemaDialect
+	result["title"] = "Nagios-Plugin exporter configuration"
+	result["$defs"] = g.defs
+
+	return map[string]interface{}(result)
+}
+
+// MarshalJSONSchema renders the result of JSONSchema as indented JSON document
+func MarshalJSONSchema() ([]byte, error) {
+	return json.MarshalIndent(JSONSchema(), "", "  ")
+}
+
+func (g *schemaGenerator) ref(name string) schemaObject {
+	return schemaObject{"$ref": "#/$defs/" + name}
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) schemaObject {
+	if s, ok := schemaTypes[t]; ok {
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = s
+		}
+
+		return g.ref(t.Name())
+	}
+
+	if t == tlsVersionType {
+		versions := make([]string, 0, len(promconfig.TLSVersions))
+		for v := range promconfig.TLSVersions {
+			versions = append(versions, v)
+		}
+		sort.Strings(versions)
+
+		return schemaObject{"type": "string", "enum": versions}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return schemaObject{"type": "string"}
+	case reflect.Bool:
+		return schemaObject{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schemaObject{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schemaObject{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return schemaObject{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return schemaObject{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs[name]; !ok {
+			// register the name first to support recursive types
+			g.defs[name] = schemaObject{}
+			s := g.structSchema(t)
+			if override, ok := schemaOverrides[name]; ok {
+				s = override(s)
+			}
+			g.defs[name] = s
+		}
+
+		return g.ref(name)
+	}
+
+	return schemaObject{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) schemaObject {
+	properties := schemaObject{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		properties[name] = g.typeSchema(f.Type)
+	}
+
+	result := schemaObject{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	return result
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestJSONSchema(t *testing.T) {
+	data, err := MarshalJSONSchema()
+	assert.NilError(t, err)
+
+	var got struct {
+		Schema string                     `json:"$schema"`
+		Ref    string                     `json:"$ref"`
+		Defs   map[string]json.RawMessage `json:"$defs"`
+	}
+	assert.NilError(t, json.Unmarshal(data, &got))
+
+	assert.Equal(t, SchemaDialect, got.Schema)
+	assert.Equal(t, "#/$defs/Config", got.Ref)
+	for _, def := range []string{"Config", "Module", "Argument", "NRPE", "TLSConfig", "Variable", "LazyArray", "BoolString", "NumberDuration"} {
+		_, ok := got.Defs[def]
+		assert.Assert(t, ok, "missing definition %s", def)
+	}
+
+	var module struct {
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	assert.NilError(t, json.Unmarshal(got.Defs["Module"], &module))
+	assert.Equal(t, "#/$defs/NumberDuration", module.Properties["timeout"]["$ref"])
+	assert.Equal(t, "#/$defs/Variable", module.Properties["variables"]["additionalProperties"].(map[string]interface{})["$ref"])
+	assert.DeepEqual(t, []interface{}{ModuleTypeExec, ModuleTypeNRPE}, module.Properties["type"]["enum"])
+}
+
+func TestJSONSchemaDurationPattern(t *testing.T) {
+	def := schemaTypes[numberDurationType]["oneOf"].([]schemaObject)[1]
+	pattern := regexp.MustCompile(def["pattern"].(string))
+
+	testCases := map[string]bool{
+		"30s":    true,
+		"1m30s":  true,
+		"500ms":  true,
+		"1.5s":   true,
+		".5s":    true,
+		"-1m":    true,
+		"2h45m":  true,
+		"10µs":   true,
+		"0":      true,
+		"1d":     false,
+		"1w":     false,
+		"1":      false,
+		".s":     false,
+		"short":  false,
+		"1h 30m": false,
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			_, err := time.ParseDuration(have)
+
+			assert.Equal(t, want, err == nil)
+			assert.Equal(t, want, pattern.MatchString(have))
+		})
+	}
+}
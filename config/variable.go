@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Variable declares a module variable along with its default value.
+// Variables without further settings can be declared using their
+// value only.
+type Variable struct {
+	Value LazyArray `yaml:"value,omitempty" json:"value,omitempty"`
+	// ValueFile is read to obtain the default value, which is
+	// never rendered as part of the configuration
+	ValueFile string `yaml:"value_file,omitempty" json:"value_file,omitempty"`
+	// Secret variables are redacted in every output
+	Secret bool `yaml:"secret,omitempty" json:"secret,omitempty"`
+	// Type restricts the values provided by probe requests.
+	// One of: [string, int, bool, duration, host, port, url, enum]
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+	// Pattern is a regular expression request values have to match
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	// Allowed lists the accepted request values; required for enums
+	Allowed []string `yaml:"allowed,omitempty" json:"allowed,omitempty"`
+	// AllowDash permits request values starting with a dash,
+	// which are refused to prevent argument injection
+	AllowDash bool `yaml:"allow_dash,omitempty" json:"allow_dash,omitempty"`
+	// Overridable controls whether probe requests may provide
+	// values for the variable; defaults to true
+	Overridable *bool `yaml:"overridable,omitempty" json:"overridable,omitempty"`
+	// Split is a separator dividing each request value into multiple
+	// values; SplitPattern is a regular expression doing the same
+	Split        string `yaml:"split,omitempty" json:"split,omitempty"`
+	SplitPattern string `yaml:"split_pattern,omitempty" json:"split_pattern,omitempty"`
+}
+
+// SplitValue divides the given request value using the separator
+// or pattern of the variable. Surrounding whitespace is removed
+// from the parts. Values are returned as is if the variable
+// declares neither.
+func (v *Variable) SplitValue(value string) []string {
+	var result []string
+	if v.Split != "" {
+		result = strings.Split(value, v.Split)
+	} else if pattern, err := compilePattern(v.SplitPattern); err == nil && pattern != nil {
+		result = pattern.Split(value, -1)
+	} else {
+		return []string{value}
+	}
+
+	for i, s := range result {
+		result[i] = strings.TrimSpace(s)
+	}
+
+	return result
+}
+
+// IsOverridable reports whether probe requests may provide values
+func (v *Variable) IsOverridable() bool {
+	return v.Overridable == nil || *v.Overridable
+}
+
+// UnmarshalYAML populates the instance fields from the given data
+// node. The value file is read once the location of the configuration
+// file is known, as relative paths are resolved against it.
+func (v *Variable) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return value.Decode(&v.Value)
+	}
+
+	type rawVariable Variable
+	if err := decodeKnownFields(value, (*rawVariable)(v)); err != nil {
+		return err
+	}
+
+	if v.ValueFile != "" && len(v.Value) > 0 {
+		return fmt.Errorf("Variable value and value_file are mutually exclusive")
+	}
+
+	return v.validate()
+}
+
+// readValueFile reads the default value from the value file, if any.
+// Relative paths are resolved against the given directory and
+// replaced by the result.
+func (v *Variable) readValueFile(dir string) error {
+	if v.ValueFile == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(v.ValueFile) {
+		v.ValueFile = filepath.Join(dir, v.ValueFile)
+	}
+
+	data, err := os.ReadFile(v.ValueFile)
+	if err != nil {
+		return fmt.Errorf("Unable to read variable value file: %s", err)
+	}
+
+	v.Value = LazyArray{strings.TrimRight(string(data), "\r\n")}
+
+	return v.validate()
+}
+
+// MarshalYAML renders the instance using the short notation if possible.
+// Values read from a file are omitted.
+func (v Variable) MarshalYAML() (interface{}, error) {
+	if v.short() {
+		return v.Value, nil
+	}
+
+	type rawVariable Variable
+	return rawVariable(v.withoutFileValue()), nil
+}
+
+// MarshalJSON renders the instance using the short notation if possible.
+// Values read from a file are omitted.
+func (v Variable) MarshalJSON() ([]byte, error) {
+	if v.short() {
+		return json.Marshal(v.Value)
+	}
+
+	type rawVariable Variable
+	return json.Marshal(rawVariable(v.withoutFileValue()))
+}
+
+// short reports whether the instance can be rendered as plain value
+func (v Variable) short() bool {
+	return reflect.DeepEqual(v, Variable{Value: v.Value})
+}
+
+func (v Variable) withoutFileValue() Variable {
+	if v.ValueFile != "" {
+		v.Value = nil
+	}
+
+	return v
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestVariable(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "community")
+	assert.NilError(t, os.WriteFile(file, []byte("s3cr3t\n"), 0600))
+
+	type testCase struct {
+		have      string
+		want      Variable
+		wantError string
+	}
+
+	testCases := map[string]testCase{
+		"string": testCase{
+			have: `"localhost"`,
+			want: Variable{Value: LazyArray{"localhost"}},
+		},
+		"array": testCase{
+			have: `["one", "two"]`,
+			want: Variable{Value: LazyArray{"one", "two"}},
+		},
+		"mapping": testCase{
+			have: `{value: public, secret: true}`,
+			want: Variable{Value: LazyArray{"public"}, Secret: true},
+		},
+		"value file": testCase{
+			have: `{value_file: "` + file + `", secret: true}`,
+			want: Variable{Value: LazyArray{"s3cr3t"}, ValueFile: file, Secret: true},
+		},
+		"relative value file": testCase{
+			have: `{value_file: community, secret: true}`,
+			want: Variable{Value: LazyArray{"s3cr3t"}, ValueFile: file, Secret: true},
+		},
+		"missing value file": testCase{
+			have:      `{value_file: "` + filepath.Join(dir, "missing") + `"}`,
+			wantError: "Unable to read variable value file",
+		},
+		"value and value file": testCase{
+			have:      `{value: public, value_file: "` + file + `"}`,
+			wantError: "Variable value and value_file are mutually exclusive",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			var got Variable
+			err := yaml.Unmarshal([]byte(tc.have), &got)
+			if err == nil {
+				err = got.readValueFile(dir)
+			}
+
+			if tc.wantError != "" {
+				assert.ErrorContains(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestVariableMarshal(t *testing.T) {
+	type testCase struct {
+		have     Variable
+		wantYAML string
+		wantJSON string
+	}
+
+	testCases := map[string]testCase{
+		"short": testCase{
+			have:     Variable{Value: LazyArray{"localhost"}},
+			wantYAML: "- localhost\n",
+			wantJSON: `["localhost"]`,
+		},
+		"secret": testCase{
+			have:     Variable{Value: LazyArray{"public"}, Secret: true},
+			wantYAML: "value:\n    - public\nsecret: true\n",
+			wantJSON: `{"value":["public"],"secret":true}`,
+		},
+		"value file": testCase{
+			have:     Variable{Value: LazyArray{"s3cr3t"}, ValueFile: "/etc/community", Secret: true},
+			wantYAML: "value_file: /etc/community\nsecret: true\n",
+			wantJSON: `{"value_file":"/etc/community","secret":true}`,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			gotYAML, err := yaml.Marshal(tc.have)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantYAML, string(gotYAML))
+
+			gotJSON, err := json.Marshal(tc.have)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantJSON, string(gotJSON))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Variable types restricting the values of probe requests
+const (
+	VariableTypeString   = "string"
+	VariableTypeInt      = "int"
+	VariableTypeBool     = "bool"
+	VariableTypeDuration = "duration"
+	VariableTypeHost     = "host"
+	VariableTypePort     = "port"
+	VariableTypeURL      = "url"
+	VariableTypeEnum     = "enum"
+)
+
+// VariableTypes lists all supported variable types
+var VariableTypes = []string{
+	VariableTypeString,
+	VariableTypeInt,
+	VariableTypeBool,
+	VariableTypeDuration,
+	VariableTypeHost,
+	VariableTypePort,
+	VariableTypeURL,
+	VariableTypeEnum,
+}
+
+var (
+	errLeadingDash = errors.New("values starting with a dash are not allowed")
+	errNotAllowed  = errors.New("value is not allowed")
+
+	hostnamePattern = regexp.MustCompile(`^([A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?)(\.[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?)*\.?$`)
+
+	// patterns caches the compiled regular expressions of variables
+	patterns sync.Map
+)
+
+// validate checks the variable settings and its default values
+func (v *Variable) validate() error {
+	if v.Type != "" && !slices.Contains(VariableTypes, v.Type) {
+		return fmt.Errorf("Unsupported variable type %q", v.Type)
+	}
+
+	if v.Type == VariableTypeEnum && len(v.Allowed) == 0 {
+		return fmt.Errorf("Variable type %q requires the allowed values", v.Type)
+	}
+
+	if _, err := v.pattern(); err != nil {
+		return fmt.Errorf("Invalid variable pattern: %s", err)
+	}
+
+	if v.Split != "" && v.SplitPattern != "" {
+		return fmt.Errorf("Variable split and split_pattern are mutually exclusive")
+	} else if _, err := compilePattern(v.SplitPattern); err != nil {
+		return fmt.Errorf("Invalid variable split pattern: %s", err)
+	}
+
+	for _, value := range v.Value {
+		if value == "" {
+			continue
+		}
+
+		if err := v.check(value); err != nil && v.Secret {
+			return fmt.Errorf("Invalid secret variable value: %s", err)
+		} else if err != nil {
+			return fmt.Errorf("Invalid variable value %q: %s", value, err)
+		}
+	}
+
+	return nil
+}
+
+// Check reports whether the given value, provided by a probe
+// request, satisfies the type, pattern and allowed values
+func (v *Variable) Check(value string) error {
+	if strings.HasPrefix(value, "-") && !v.AllowDash {
+		return errLeadingDash
+	}
+
+	return v.check(value)
+}
+
+func (v *Variable) check(value string) error {
+	if err := checkVariableType(v.Type, value); err != nil {
+		return err
+	}
+
+	if len(v.Allowed) > 0 && !slices.Contains(v.Allowed, value) {
+		return errNotAllowed
+	}
+
+	pattern, err := v.pattern()
+	if err != nil {
+		return err
+	} else if pattern != nil && !pattern.MatchString(value) {
+		return fmt.Errorf("value does not match the pattern %q", v.Pattern)
+	}
+
+	return nil
+}
+
+// pattern compiles the variable pattern, which has to match the entire value
+func (v *Variable) pattern() (*regexp.Regexp, error) {
+	if v.Pattern == "" {
+		return nil, nil
+	}
+
+	return compilePattern("^(?:" + v.Pattern + ")$")
+}
+
+// compilePattern compiles the given regular expression, reusing
+// previous results. The result is nil for empty expressions.
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	if cached, ok := patterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	result, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, result)
+
+	return result, nil
+}
+
+func checkVariableType(t, value string) error {
+	switch t {
+	case VariableTypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.New("value is not an integer")
+		}
+	case VariableTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("value is not a boolean")
+		}
+	case VariableTypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return errors.New("value is not a duration")
+		}
+	case VariableTypeHost:
+		if net.ParseIP(value) == nil && (len(value) > 253 || !hostnamePattern.MatchString(value)) {
+			return errors.New("value is neither a hostname nor an IP address")
+		}
+	case VariableTypePort:
+		if port, err := strconv.ParseUint(value, 10, 16); err != nil || port == 0 {
+			return errors.New("value is not a port number")
+		}
+	case VariableTypeURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("value is not an absolute URL")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestVariableCheck(t *testing.T) {
+	type testCase struct {
+		have      Variable
+		value     string
+		wantError string
+	}
+
+	testCases := map[string]testCase{
+		"string": testCase{
+			have:  Variable{},
+			value: "anything goes",
+		},
+		"leading dash": testCase{
+			have:      Variable{},
+			value:     "--help",
+			wantError: "values starting with a dash are not allowed",
+		},
+		"leading dash allowed": testCase{
+			have:  Variable{Type: VariableTypeInt, AllowDash: true},
+			value: "-5",
+		},
+		"int": testCase{
+			have:  Variable{Type: VariableTypeInt},
+			value: "42",
+		},
+		"invalid int": testCase{
+			have:      Variable{Type: VariableTypeInt},
+			value:     "4 2",
+			wantError: "value is not an integer",
+		},
+		"bool": testCase{
+			have:  Variable{Type: VariableTypeBool},
+			value: "true",
+		},
+		"invalid bool": testCase{
+			have:      Variable{Type: VariableTypeBool},
+			value:     "maybe",
+			wantError: "value is not a boolean",
+		},
+		"duration": testCase{
+			have:  Variable{Type: VariableTypeDuration},
+			value: "1m30s",
+		},
+		"invalid duration": testCase{
+			have:      Variable{Type: VariableTypeDuration},
+			value:     "soon",
+			wantError: "value is not a duration",
+		},
+		"hostname": testCase{
+			have:  Variable{Type: VariableTypeHost},
+			value: "www.example.com",
+		},
+		"ipv6": testCase{
+			have:  Variable{Type: VariableTypeHost},
+			value: "::1",
+		},
+		"invalid host": testCase{
+			have:      Variable{Type: VariableTypeHost},
+			value:     "example.com; rm -rf /",
+			wantError: "value is neither a hostname nor an IP address",
+		},
+		"port": testCase{
+			have:  Variable{Type: VariableTypePort},
+			value: "8443",
+		},
+		"invalid port": testCase{
+			have:      Variable{Type: VariableTypePort},
+			value:     "65536",
+			wantError: "value is not a port number",
+		},
+		"url": testCase{
+			have:  Variable{Type: VariableTypeURL},
+			value: "https://example.com/health",
+		},
+		"relative url": testCase{
+			have:      Variable{Type: VariableTypeURL},
+			value:     "/health",
+			wantError: "value is not an absolute URL",
+		},
+		"enum": testCase{
+			have:  Variable{Type: VariableTypeEnum, Allowed: []string{"ok", "warning"}},
+			value: "warning",
+		},
+		"not allowed": testCase{
+			have:      Variable{Type: VariableTypeEnum, Allowed: []string{"ok", "warning"}},
+			value:     "critical",
+			wantError: "value is not allowed",
+		},
+		"pattern": testCase{
+			have:  Variable{Pattern: `[a-z]+`},
+			value: "abc",
+		},
+		"pattern partial match": testCase{
+			have:      Variable{Pattern: `[a-z]+`},
+			value:     "abc1",
+			wantError: `value does not match the pattern "[a-z]+"`,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			err := tc.have.Check(tc.value)
+
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestVariableValidate(t *testing.T) {
+	type testCase struct {
+		have      string
+		wantError string
+	}
+
+	testCases := map[string]testCase{
+		"typed": testCase{
+			have: `{value: 80, type: port}`,
+		},
+		"negative default": testCase{
+			have: `{value: -1, type: int}`,
+		},
+		"unknown type": testCase{
+			have:      `{type: float}`,
+			wantError: `Unsupported variable type "float"`,
+		},
+		"enum without values": testCase{
+			have:      `{type: enum}`,
+			wantError: `Variable type "enum" requires the allowed values`,
+		},
+		"invalid pattern": testCase{
+			have:      `{pattern: "[a-z"}`,
+			wantError: "Invalid variable pattern: error parsing regexp: missing closing ]: `[a-z)$`",
+		},
+		"split": testCase{
+			have: `{split: ",", type: host}`,
+		},
+		"split and split pattern": testCase{
+			have:      `{split: ",", split_pattern: "[,;]"}`,
+			wantError: "Variable split and split_pattern are mutually exclusive",
+		},
+		"invalid split pattern": testCase{
+			have:      `{split_pattern: "[,;"}`,
+			wantError: "Invalid variable split pattern: error parsing regexp: missing closing ]: `[,;`",
+		},
+		"invalid default": testCase{
+			have:      `{value: http, type: port}`,
+			wantError: `Invalid variable value "http": value is not a port number`,
+		},
+		"invalid secret default": testCase{
+			have:      `{value: s3cr3t, secret: true, allowed: [public]}`,
+			wantError: "Invalid secret variable value: value is not allowed",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			var got Variable
+			err := yaml.Unmarshal([]byte(tc.have), &got)
+
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestVariableSplitValue(t *testing.T) {
+	type testCase struct {
+		have  Variable
+		value string
+		want  []string
+	}
+
+	testCases := map[string]testCase{
+		"no split": testCase{
+			have:  Variable{},
+			value: "a,b",
+			want:  []string{"a,b"},
+		},
+		"separator": testCase{
+			have:  Variable{Split: ","},
+			value: "/, /var ,/home",
+			want:  []string{"/", "/var", "/home"},
+		},
+		"pattern": testCase{
+			have:  Variable{SplitPattern: `[,;]`},
+			value: "200;301,302",
+			want:  []string{"200", "301", "302"},
+		},
+		"empty parts": testCase{
+			have:  Variable{Split: ","},
+			value: "a,,b,",
+			want:  []string{"a", "", "b", ""},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			assert.DeepEqual(t, tc.want, tc.have.SplitValue(tc.value))
+		})
+	}
+}
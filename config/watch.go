@@ -0,0 +1,236 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"gopkg.in/yaml.v3"
+)
+
+// Fingerprint describes the content of a configuration
+// file and all of its includes at a given time
+type Fingerprint struct {
+	// Hash is the checksum over the names and contents of all files
+	Hash string
+	// Dirs are the directories containing the files
+	// or being referenced by include patterns
+	Dirs []string
+}
+
+// NewFingerprint reads the given configuration file and its includes.
+// Unlike LoadConfig, errors are tolerated: unreadable files contribute
+// to the hash by their absence and undecodable ones by their content.
+func NewFingerprint(confFile string) *Fingerprint {
+	f := &fingerprinter{
+		hash: sha256.New(),
+		dirs: make(map[string]bool),
+		seen: make(map[string]bool),
+	}
+	f.add(confFile)
+
+	dirs := make([]string, 0, len(f.dirs))
+	for dir := range f.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	result := &Fingerprint{
+		Hash: hex.EncodeToString(f.hash.Sum(nil)),
+		Dirs: dirs,
+	}
+
+	return result
+}
+
+type fingerprinter struct {
+	hash hash.Hash
+	dirs map[string]bool
+	seen map[string]bool
+}
+
+func (f *fingerprinter) add(file string) {
+	file = filepath.Clean(file)
+	if f.seen[file] {
+		return
+	}
+	f.seen[file] = true
+	f.dirs[filepath.Dir(file)] = true
+
+	f.hash.Write([]byte(file))
+	f.hash.Write([]byte{0})
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	f.hash.Write(data)
+	f.hash.Write([]byte{0})
+
+	var c struct {
+		Include []string `yaml:"include"`
+		Modules map[string]struct {
+			Variables map[string]yaml.Node `yaml:"variables"`
+		} `yaml:"modules"`
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return
+	}
+
+	for _, name := range sortedKeys(c.Modules) {
+		variables := c.Modules[name].Variables
+		for _, k := range sortedKeys(variables) {
+			var v struct {
+				ValueFile string `yaml:"value_file"`
+			}
+			// the short notation has no value file
+			if node := variables[k]; node.Kind != yaml.MappingNode || node.Decode(&v) != nil || v.ValueFile == "" {
+				continue
+			}
+
+			if !filepath.IsAbs(v.ValueFile) {
+				v.ValueFile = filepath.Join(filepath.Dir(file), v.ValueFile)
+			}
+			f.addValueFile(v.ValueFile)
+		}
+	}
+
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+
+		// new files matching the pattern are created in this directory
+		if dir := filepath.Dir(pattern); !strings.ContainsAny(dir, `*?[\`) {
+			f.dirs[dir] = true
+		}
+
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				f.add(match)
+			}
+		}
+	}
+}
+
+// addValueFile adds the name and content of a variable value file
+func (f *fingerprinter) addValueFile(file string) {
+	file = filepath.Clean(file)
+	if f.seen[file] {
+		return
+	}
+	f.seen[file] = true
+	f.dirs[filepath.Dir(file)] = true
+
+	f.hash.Write([]byte(file))
+	f.hash.Write([]byte{0})
+
+	if data, err := os.ReadFile(file); err == nil {
+		f.hash.Write(data)
+		f.hash.Write([]byte{0})
+	}
+}
+
+// notifier reports file system events in a set of directories
+type notifier interface {
+	// Watch replaces the set of observed directories
+	Watch(dirs []string) error
+	// Events signals file system activity in the observed directories
+	Events() <-chan struct{}
+	Close() error
+}
+
+// Watch observes the given configuration file and its includes, sending
+// a signal on the returned channel whenever their content has changed.
+// File system notifications are used where available, otherwise the
+// files are polled using the given interval. Changes are reported once
+// the files have not been modified for the debounce duration.
+// The channel is closed once the context is done.
+func Watch(ctx context.Context, confFile string, interval, debounce time.Duration, logger log.Logger) <-chan struct{} {
+	result := make(chan struct{}, 1)
+
+	current := NewFingerprint(confFile)
+
+	n, err := newNotifier()
+	if err == nil {
+		err = n.Watch(current.Dirs)
+	}
+
+	if err != nil {
+		level.Info(logger).Log("msg", "File system notifications unavailable, polling the config files", "interval", interval, "err", err)
+		if n != nil {
+			n.Close()
+			n = nil
+		}
+	}
+
+	go func() {
+		defer close(result)
+
+		var events <-chan struct{}
+		var poll <-chan time.Time
+		if n != nil {
+			defer n.Close()
+			events = n.Events()
+		} else {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			poll = ticker.C
+		}
+
+		settle := time.NewTimer(debounce)
+		settle.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				settle.Stop()
+				return
+			case _, ok := <-events:
+				if !ok {
+					level.Warn(logger).Log("msg", "File system notifications stopped, polling the config files", "interval", interval)
+					ticker := time.NewTicker(interval)
+					defer ticker.Stop()
+					events, poll = nil, ticker.C
+					n = nil
+					continue
+				}
+
+				settle.Reset(debounce)
+			case <-poll:
+				if NewFingerprint(confFile).Hash != current.Hash {
+					settle.Reset(debounce)
+				}
+			case <-settle.C:
+				next := NewFingerprint(confFile)
+				if next.Hash == current.Hash {
+					continue
+				}
+
+				if n != nil {
+					if err := n.Watch(next.Dirs); err != nil {
+						level.Warn(logger).Log("msg", "Unable to watch config directories", "err", err)
+					}
+				}
+
+				current = next
+				select {
+				case result <- struct{}{}:
+				default:
+					// a reload is pending already
+				}
+			}
+		}
+	}()
+
+	return result
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestNewFingerprint(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yml":        "include: [conf.d/*.yml]\n",
+		"conf.d/http.yml": "modules:\n  http:\n    command: /bin/check_http\n",
+	})
+	main := filepath.Join(dir, "main.yml")
+
+	before := NewFingerprint(main)
+	assert.DeepEqual(t, []string{dir, filepath.Join(dir, "conf.d")}, before.Dirs)
+	assert.Equal(t, before.Hash, NewFingerprint(main).Hash)
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "conf.d", "ping.yml"), []byte("modules: {}\n"), 0644))
+	added := NewFingerprint(main)
+	assert.Assert(t, before.Hash != added.Hash)
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "conf.d", "http.yml"), []byte("modules: {\n"), 0644))
+	broken := NewFingerprint(main)
+	assert.Assert(t, added.Hash != broken.Hash)
+
+	assert.NilError(t, os.Remove(main))
+	missing := NewFingerprint(main)
+	assert.Assert(t, broken.Hash != missing.Hash)
+	assert.Assert(t, cmp.Len(missing.Dirs, 1))
+}
+
+func TestNewFingerprintValueFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yml":          "modules:\n  snmp:\n    command: /bin/check_snmp\n    variables:\n      community: {value_file: secrets/community, secret: true}\n",
+		"secrets/community": "public\n",
+	})
+	main := filepath.Join(dir, "main.yml")
+
+	before := NewFingerprint(main)
+	assert.DeepEqual(t, []string{dir, filepath.Join(dir, "secrets")}, before.Dirs)
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "secrets", "community"), []byte("private\n"), 0600))
+	rotated := NewFingerprint(main)
+	assert.Assert(t, before.Hash != rotated.Hash)
+}
+
+func TestWatch(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yml": "include: [conf.d/*.yml]\n",
+	})
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := Watch(ctx, filepath.Join(dir, "main.yml"), 50*time.Millisecond, 50*time.Millisecond, log.NewNopLogger())
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "conf.d", "http.yml"), []byte("modules: {}\n"), 0644))
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change of an included file has not been reported")
+	}
+
+	// touching a file without changing its content is no change
+	now := time.Now()
+	assert.NilError(t, os.Chtimes(filepath.Join(dir, "main.yml"), now, now))
+
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-changes:
+		assert.Assert(t, !ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel has not been closed")
+	}
+}
+
+func TestNotifierClose(t *testing.T) {
+	subject, err := newNotifier()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	dir := t.TempDir()
+	assert.NilError(t, subject.Watch([]string{dir}))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "main.yml"), []byte("modules: {}\n"), 0644))
+
+	select {
+	case <-subject.Events():
+	case <-time.After(5 * time.Second):
+		t.Fatal("change has not been reported")
+	}
+
+	// give the reader a chance to block on the descriptor again
+	time.Sleep(100 * time.Millisecond)
+	assert.NilError(t, subject.Close())
+
+	// closing has to interrupt the pending read
+	select {
+	case _, ok := <-subject.Events():
+		assert.Assert(t, !ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("events have not been closed")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/importer"
+)
+
+var (
+	importCommand = kingpin.Command("import", "Convert monitoring configuration into exporter modules.")
+
+	importIcingaCommand = importCommand.Command("icinga", "Convert Icinga 2 CheckCommand definitions.")
+	importIcingaFiles   = importIcingaCommand.Arg("file", "Icinga 2 configuration files containing CheckCommand objects.").Required().ExistingFiles()
+	importIcingaConsts  = importIcingaCommand.Flag("const", "Value of a constant referenced by the definitions (e.g. PluginDir=/usr/lib/nagios/plugins).").StringMap()
+
+	importNagiosCommand   = importCommand.Command("nagios", "Convert Nagios command definitions.")
+	importNagiosFiles     = importNagiosCommand.Arg("file", "Nagios object configuration files containing command definitions.").Required().ExistingFiles()
+	importNagiosResources = importNagiosCommand.Flag("resource", "Nagios resource file defining $USERn$ macros.").ExistingFiles()
+)
+
+func runImportIcinga(logger log.Logger) int {
+	i := importer.NewIcingaImporter(*importIcingaConsts)
+
+	for _, file := range *importIcingaFiles {
+		if err := parseImportFile(file, i.Parse, logger); err != nil {
+			return 1
+		}
+	}
+
+	modules, warnings := i.Modules()
+	for _, w := range warnings {
+		level.Warn(logger).Log("msg", "Incomplete import", "err", w)
+	}
+
+	return printModules(modules, logger)
+}
+
+func runImportNagios(logger log.Logger) int {
+	i := importer.NewNagiosImporter()
+
+	for _, file := range *importNagiosResources {
+		if err := parseImportFile(file, i.ParseResources, logger); err != nil {
+			return 1
+		}
+	}
+
+	for _, file := range *importNagiosFiles {
+		if err := parseImportFile(file, i.Parse, logger); err != nil {
+			return 1
+		}
+	}
+
+	modules, warnings := i.Modules()
+	for _, w := range warnings {
+		level.Warn(logger).Log("msg", "Incomplete import", "err", w)
+	}
+
+	return printModules(modules, logger)
+}
+
+func parseImportFile(file string, parse func(string, io.Reader) error, logger log.Logger) error {
+	r, err := os.Open(file)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error reading import file", "err", err)
+		return err
+	}
+	defer r.Close()
+
+	if err := parse(file, r); err != nil {
+		level.Error(logger).Log("msg", "Error parsing import file", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+func printModules(modules map[string]config.Module, logger log.Logger) int {
+	c := &config.Config{
+		Modules: modules,
+	}
+
+	b, err := c.MarshalYAML()
+	if err != nil {
+		level.Error(logger).Log("msg", "Error rendering modules", "err", err)
+		return 1
+	}
+
+	fmt.Fprint(os.Stdout, string(b))
+
+	return 0
+}
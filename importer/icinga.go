@@ -0,0 +1,498 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+// commandOrder is the base order of additional command array
+// items, which Icinga places in front of all arguments
+const commandOrder = -1000
+
+// DefaultIcingaConstants are the constants of a stock Icinga 2 installation
+// which are used by the Icinga Template Library
+var DefaultIcingaConstants = map[string]string{
+	"PluginDir":          "/usr/lib/nagios/plugins",
+	"PluginContribDir":   "/usr/lib/nagios/plugins",
+	"ManubulonPluginDir": "/usr/lib/nagios/plugins",
+}
+
+// icingaVariable returns the variable name for the given macro name
+func icingaVariable(name string) string {
+	return sanitizeVariable(config.MacroVariable(name))
+}
+
+// IcingaImporter converts Icinga 2 CheckCommand definitions into modules
+type IcingaImporter struct {
+	consts  map[string]string
+	parsed  []*icingaParser
+	objects map[string]*icingaObject
+}
+
+// NewIcingaImporter creates a new importer using the given constants
+// in addition to the DefaultIcingaConstants
+func NewIcingaImporter(consts map[string]string) *IcingaImporter {
+	c := make(map[string]string, len(DefaultIcingaConstants)+len(consts))
+	for k, v := range DefaultIcingaConstants {
+		c[k] = v
+	}
+	for k, v := range consts {
+		c[k] = v
+	}
+
+	result := &IcingaImporter{
+		consts:  c,
+		parsed:  []*icingaParser{},
+		objects: map[string]*icingaObject{},
+	}
+
+	return result
+}
+
+// Parse reads the CheckCommand definitions from the given source.
+// Other object types are ignored.
+func (i *IcingaImporter) Parse(source string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	p, err := parseIcinga(source, string(b))
+	if err != nil {
+		return err
+	}
+
+	for _, o := range p.objects {
+		if prev, ok := i.objects[o.name]; ok {
+			return fmt.Errorf("%s:%d: CheckCommand %q has already been defined in %s:%d", o.source, o.line, o.name, prev.source, prev.line)
+		}
+
+		i.objects[o.name] = o
+	}
+
+	i.parsed = append(i.parsed, p)
+
+	return nil
+}
+
+// Modules converts all parsed CheckCommand objects (templates are only used
+// for imports) into modules. Anything which can not be represented is
+// reported as warning, leaving out the affected attribute.
+func (i *IcingaImporter) Modules() (map[string]config.Module, []error) {
+	warnings := []error{}
+	consts := i.evalConsts(&warnings)
+	modules := map[string]config.Module{}
+
+	names := make([]string, 0, len(i.objects))
+	for name := range i.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o := i.objects[name]
+		if o.template {
+			continue
+		}
+
+		attrs, err := i.resolve(o, consts, map[string]bool{}, &warnings)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue
+		}
+
+		c := &icingaConverter{source: o.source, line: o.line, name: o.name, warnings: &warnings}
+		if module, ok := c.module(attrs); ok {
+			modules[o.name] = module
+		}
+	}
+
+	return modules, warnings
+}
+
+func (i *IcingaImporter) evalConsts(warnings *[]error) map[string]interface{} {
+	result := make(map[string]interface{}, len(i.consts))
+	for k, v := range i.consts {
+		result[k] = v
+	}
+
+	for _, p := range i.parsed {
+		e := &icingaEvaluator{source: p.lexer.source, consts: result}
+		for _, s := range p.consts {
+			// provided constants take precedence over the parsed ones
+			if _, ok := i.consts[s.path[0]]; ok {
+				continue
+			}
+
+			v, err := e.eval(s.value)
+			if err != nil {
+				*warnings = append(*warnings, err)
+				continue
+			}
+
+			result[s.path[0]] = v
+		}
+	}
+
+	return result
+}
+
+// resolve evaluates the object attributes, including those of imported templates
+func (i *IcingaImporter) resolve(o *icingaObject, consts map[string]interface{}, visiting map[string]bool, warnings *[]error) (*icingaDictionary, error) {
+	if visiting[o.name] {
+		return nil, fmt.Errorf("%s:%d: CheckCommand %q imports itself", o.source, o.line, o.name)
+	}
+
+	visiting[o.name] = true
+	defer delete(visiting, o.name)
+
+	e := &icingaEvaluator{source: o.source, consts: consts}
+	result := newIcingaDictionary()
+
+	for _, s := range o.body {
+		if s.imp == nil {
+			if err := e.apply(result, s); err != nil {
+				*warnings = append(*warnings, err)
+			}
+			continue
+		}
+
+		v, err := e.eval(s.imp)
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := v.(string)
+		if !ok {
+			return nil, e.errorf(s.line, "import requires a string, got %s", icingaTypeName(v))
+		}
+
+		parent, ok := i.objects[name]
+		if !ok {
+			*warnings = append(*warnings, e.errorf(s.line, "CheckCommand %q imports unknown template %q", o.name, name))
+			continue
+		}
+
+		attrs, err := i.resolve(parent, consts, visiting, warnings)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range attrs.keys {
+			result.Set(k, attrs.values[k])
+		}
+	}
+
+	return result, nil
+}
+
+type icingaConverter struct {
+	source   string
+	line     int
+	name     string
+	warnings *[]error
+	vars     []string
+}
+
+func (c *icingaConverter) warnf(format string, args ...interface{}) {
+	*c.warnings = append(*c.warnings, fmt.Errorf("%s:%d: CheckCommand %q: %s", c.source, c.line, c.name, fmt.Sprintf(format, args...)))
+}
+
+func (c *icingaConverter) template(s, pipeline string) string {
+	t, vars := macroTemplate(s, pipeline, icingaVariable)
+	c.vars = append(c.vars, vars...)
+
+	return t
+}
+
+func (c *icingaConverter) module(attrs *icingaDictionary) (config.Module, bool) {
+	module := config.Module{
+		Arguments:   map[string]config.Argument{},
+		Variables:   map[string]config.Variable{},
+		Environment: map[string]string{},
+	}
+
+	for _, k := range attrs.Keys() {
+		v := attrs.values[k]
+
+		switch k {
+		case "command":
+			c.command(&module, v)
+		case "arguments":
+			c.arguments(&module, v)
+		case "vars":
+			c.variables(&module, v)
+		case "env":
+			c.environment(&module, v)
+		case "timeout":
+			if f, ok := v.(float64); ok {
+				module.Timeout = config.NumberDuration(time.Duration(f * float64(time.Second)))
+			} else {
+				c.warnf("unsupported timeout of type %s", icingaTypeName(v))
+			}
+		case "execute", "zone", "templates", "vars_override":
+			// internal attributes without any meaning to the exporter
+		default:
+			c.warnf("unsupported attribute '%s'", k)
+		}
+	}
+
+	if module.Command == "" {
+		c.warnf("missing command")
+		return module, false
+	}
+
+	// referenced variables must be declared to be
+	// populated from the probe request
+	for _, v := range c.vars {
+		if _, ok := module.Variables[v]; !ok {
+			module.Variables[v] = config.Variable{Value: config.LazyArray{""}}
+		}
+	}
+
+	return module, true
+}
+
+func (c *icingaConverter) command(module *config.Module, v interface{}) {
+	var items []interface{}
+	switch x := v.(type) {
+	case string:
+		items = []interface{}{x}
+	case []interface{}:
+		items = x
+	default:
+		c.warnf("unsupported command of type %s", icingaTypeName(v))
+		return
+	}
+
+	for n, item := range items {
+		s, ok := icingaScalar(item)
+		if !ok {
+			c.warnf("unsupported command item of type %s", icingaTypeName(item))
+			return
+		}
+
+		if n == 0 {
+			if hasMacros(s) {
+				c.warnf("runtime macros in the command path are not supported")
+			}
+
+			module.Command = s
+			continue
+		}
+
+		module.Arguments[fmt.Sprintf("command[%d]", n)] = config.Argument{
+			Value:   config.LazyArray{c.template(s, valuePipeline)},
+			Order:   commandOrder + n,
+			SkipKey: "true",
+		}
+	}
+}
+
+func (c *icingaConverter) arguments(module *config.Module, v interface{}) {
+	args, ok := v.(*icingaDictionary)
+	if !ok {
+		c.warnf("unsupported arguments of type %s", icingaTypeName(v))
+		return
+	}
+
+	for _, key := range args.Keys() {
+		if arg, ok := c.argument(key, args.values[key]); ok {
+			module.Arguments[key] = arg
+		}
+	}
+}
+
+func (c *icingaConverter) argument(key string, v interface{}) (arg config.Argument, ok bool) {
+	attrs, isDict := v.(*icingaDictionary)
+	if !isDict {
+		attrs = newIcingaDictionary()
+		attrs.Set("value", v)
+	}
+
+	for _, k := range attrs.Keys() {
+		v := attrs.values[k]
+
+		switch k {
+		case "value":
+			values, ok := c.values(v)
+			if !ok {
+				c.warnf("argument %q: unsupported value of type %s", key, icingaTypeName(v))
+				return arg, false
+			}
+
+			arg.Value = values
+		case "set_if":
+			s, ok := c.condition(v)
+			if !ok {
+				c.warnf("argument %q: unsupported set_if of type %s", key, icingaTypeName(v))
+				return arg, false
+			}
+
+			arg.Condition = s
+		case "required", "repeat_key", "skip_key":
+			s, ok := c.condition(v)
+			if !ok {
+				c.warnf("argument %q: unsupported %s of type %s", key, k, icingaTypeName(v))
+				continue
+			}
+
+			switch k {
+			case "required":
+				arg.Required = s
+			case "repeat_key":
+				arg.RepeatKey = s
+			case "skip_key":
+				arg.SkipKey = s
+			}
+		case "order":
+			f, ok := v.(float64)
+			if !ok {
+				c.warnf("argument %q: unsupported order of type %s", key, icingaTypeName(v))
+				continue
+			}
+
+			arg.Order = int(f)
+		case "key", "separator":
+			s, ok := v.(string)
+			if !ok {
+				c.warnf("argument %q: unsupported %s of type %s", key, k, icingaTypeName(v))
+				continue
+			}
+
+			if k == "key" {
+				arg.Key = s
+			} else {
+				arg.Separator = s
+			}
+		case "description":
+		default:
+			c.warnf("argument %q: unsupported attribute '%s'", key, k)
+		}
+	}
+
+	// Icinga always sets arguments without value and condition
+	if len(arg.Value) == 0 && arg.Condition == "" {
+		arg.Condition = "true"
+	}
+
+	return arg, true
+}
+
+func (c *icingaConverter) values(v interface{}) (config.LazyArray, bool) {
+	if s, ok := icingaScalar(v); ok {
+		return config.LazyArray{c.template(s, valuePipeline)}, true
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	result := make(config.LazyArray, 0, len(items))
+	for _, item := range items {
+		s, ok := icingaScalar(item)
+		if !ok {
+			return nil, false
+		}
+
+		result = append(result, c.template(s, valuePipeline))
+	}
+
+	return result, true
+}
+
+func (c *icingaConverter) condition(v interface{}) (config.BoolString, bool) {
+	switch x := v.(type) {
+	case bool:
+		return config.BoolString(strconv.FormatBool(x)), true
+	case float64:
+		return config.BoolString(strconv.FormatBool(x != 0)), true
+	case string:
+		return config.BoolString(c.template(x, conditionPipeline)), true
+	}
+
+	return "", false
+}
+
+func (c *icingaConverter) variables(module *config.Module, v interface{}) {
+	vars, ok := v.(*icingaDictionary)
+	if !ok {
+		c.warnf("unsupported vars of type %s", icingaTypeName(v))
+		return
+	}
+
+	for _, key := range vars.Keys() {
+		name := icingaVariable(key)
+		v := vars.values[key]
+
+		if v == nil {
+			continue
+		}
+
+		if s, ok := icingaScalar(v); ok {
+			if hasMacros(s) {
+				c.warnf("variable %q: runtime macros are not supported in defaults", key)
+				s = ""
+			}
+
+			module.Variables[name] = config.Variable{Value: config.LazyArray{unescapeMacros(s)}}
+			continue
+		}
+
+		items, ok := v.([]interface{})
+		if !ok {
+			c.warnf("variable %q: unsupported value of type %s", key, icingaTypeName(v))
+			module.Variables[name] = config.Variable{Value: config.LazyArray{""}}
+			continue
+		}
+
+		values := make(config.LazyArray, 0, len(items))
+		for _, item := range items {
+			if s, ok := icingaScalar(item); ok && !hasMacros(s) {
+				values = append(values, unescapeMacros(s))
+			} else {
+				c.warnf("variable %q: unsupported array item", key)
+			}
+		}
+
+		module.Variables[name] = config.Variable{Value: values}
+	}
+}
+
+func (c *icingaConverter) environment(module *config.Module, v interface{}) {
+	env, ok := v.(*icingaDictionary)
+	if !ok {
+		c.warnf("unsupported env of type %s", icingaTypeName(v))
+		return
+	}
+
+	for _, key := range env.Keys() {
+		s, ok := icingaScalar(env.values[key])
+		if !ok || hasMacros(s) {
+			c.warnf("environment %q: only static values are supported", key)
+			continue
+		}
+
+		module.Environment[key] = unescapeMacros(s)
+	}
+}
+
+// icingaScalar converts strings, numbers, and booleans into strings
+func icingaScalar(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case float64:
+		return formatIcingaNumber(x), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,268 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type icingaTokenType int
+
+const (
+	icingaTokenEOF icingaTokenType = iota
+	icingaTokenIdent
+	icingaTokenString
+	icingaTokenNumber
+	icingaTokenLambda
+	icingaTokenPunct
+)
+
+type icingaToken struct {
+	typ   icingaTokenType
+	text  string
+	value float64
+	line  int
+}
+
+func (t icingaToken) String() string {
+	switch t.typ {
+	case icingaTokenEOF:
+		return "end of file"
+	case icingaTokenString:
+		return strconv.Quote(t.text)
+	case icingaTokenLambda:
+		return "function expression"
+	}
+
+	return "'" + t.text + "'"
+}
+
+// icingaDurations are the duration suffixes of numeric literals
+// and their factor to convert the value into seconds
+var icingaDurations = map[string]float64{
+	"ms": 0.001,
+	"s":  1,
+	"m":  60,
+	"h":  60 * 60,
+	"d":  60 * 60 * 24,
+}
+
+// icingaPunctuation is ordered by length to match greedily
+var icingaPunctuation = []string{
+	"&&", "||", "==", "!=", "<=", ">=", "+=", "-=", "*=", "/=",
+	"{", "}", "[", "]", "(", ")", "=", ",", ";", "+", "-", ".",
+	"!", "<", ">", "*", "/", "%", "&", "|", "^", "~", "?", ":",
+}
+
+type icingaLexer struct {
+	source string
+	input  string
+	pos    int
+	line   int
+}
+
+func newIcingaLexer(source, input string) *icingaLexer {
+	result := &icingaLexer{
+		source: source,
+		input:  input,
+		line:   1,
+	}
+
+	return result
+}
+
+func (l *icingaLexer) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", l.source, line, fmt.Sprintf(format, args...))
+}
+
+func (l *icingaLexer) skip() error {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#' || strings.HasPrefix(l.input[l.pos:], "//"):
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.input[l.pos:], "/*"):
+			end := strings.Index(l.input[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf(l.line, "unterminated comment")
+			}
+
+			comment := l.input[l.pos : l.pos+2+end+2]
+			l.line += strings.Count(comment, "\n")
+			l.pos += len(comment)
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (l *icingaLexer) next() (icingaToken, error) {
+	if err := l.skip(); err != nil {
+		return icingaToken{}, err
+	}
+
+	if l.pos >= len(l.input) {
+		return icingaToken{typ: icingaTokenEOF, line: l.line}, nil
+	}
+
+	rest := l.input[l.pos:]
+	c := rest[0]
+
+	switch {
+	case strings.HasPrefix(rest, "{{{"):
+		return l.delimited("{{{", "}}}", icingaTokenString)
+	case strings.HasPrefix(rest, "{{"):
+		return l.lambda()
+	case c == '"':
+		return l.quoted()
+	case c >= '0' && c <= '9':
+		return l.number()
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := l.pos
+		for l.pos < len(l.input) && (l.input[l.pos] == '_' || unicode.IsLetter(rune(l.input[l.pos])) || unicode.IsDigit(rune(l.input[l.pos]))) {
+			l.pos++
+		}
+
+		return icingaToken{typ: icingaTokenIdent, text: l.input[start:l.pos], line: l.line}, nil
+	}
+
+	for _, p := range icingaPunctuation {
+		if strings.HasPrefix(rest, p) {
+			l.pos += len(p)
+			return icingaToken{typ: icingaTokenPunct, text: p, line: l.line}, nil
+		}
+	}
+
+	return icingaToken{}, l.errorf(l.line, "unexpected character %q", c)
+}
+
+func (l *icingaLexer) delimited(open, close string, typ icingaTokenType) (icingaToken, error) {
+	line := l.line
+	end := strings.Index(l.input[l.pos+len(open):], close)
+	if end < 0 {
+		return icingaToken{}, l.errorf(line, "unterminated %s", open)
+	}
+
+	text := l.input[l.pos+len(open) : l.pos+len(open)+end]
+	l.line += strings.Count(text, "\n")
+	l.pos += len(open) + end + len(close)
+
+	return icingaToken{typ: typ, text: text, line: line}, nil
+}
+
+// lambda consumes a function expression, skipping nested blocks
+// and string literals which might contain closing braces
+func (l *icingaLexer) lambda() (icingaToken, error) {
+	line := l.line
+	start := l.pos + 2
+	depth := 0
+
+	for i := start; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '\n':
+			l.line++
+		case '"':
+			for i++; i < len(l.input) && l.input[i] != '"'; i++ {
+				if l.input[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			} else if i+1 < len(l.input) && l.input[i+1] == '}' {
+				l.pos = i + 2
+				return icingaToken{typ: icingaTokenLambda, text: l.input[start:i], line: line}, nil
+			}
+		}
+	}
+
+	return icingaToken{}, l.errorf(line, "unterminated function expression")
+}
+
+func (l *icingaLexer) quoted() (icingaToken, error) {
+	line := l.line
+	buf := &strings.Builder{}
+	l.pos++
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		l.pos++
+
+		switch c {
+		case '"':
+			return icingaToken{typ: icingaTokenString, text: buf.String(), line: line}, nil
+		case '\n':
+			return icingaToken{}, l.errorf(line, "unterminated string")
+		case '\\':
+			if l.pos >= len(l.input) {
+				return icingaToken{}, l.errorf(line, "unterminated string")
+			}
+
+			e := l.input[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			default:
+				buf.WriteByte(e)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return icingaToken{}, l.errorf(line, "unterminated string")
+}
+
+func (l *icingaLexer) number() (icingaToken, error) {
+	start := l.pos
+	for l.pos < len(l.input) && (l.input[l.pos] >= '0' && l.input[l.pos] <= '9' || l.input[l.pos] == '.') {
+		l.pos++
+	}
+
+	text := l.input[start:l.pos]
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return icingaToken{}, l.errorf(l.line, "invalid number %q", text)
+	}
+
+	// duration literals; longest suffix first
+	for _, unit := range []string{"ms", "s", "m", "h", "d"} {
+		if !strings.HasPrefix(l.input[l.pos:], unit) {
+			continue
+		}
+
+		end := l.pos + len(unit)
+		if end < len(l.input) && (unicode.IsLetter(rune(l.input[end])) || l.input[end] == '_') {
+			continue
+		}
+
+		l.pos = end
+		text += unit
+		value *= icingaDurations[unit]
+		break
+	}
+
+	return icingaToken{typ: icingaTokenNumber, text: text, value: value, line: l.line}, nil
+}
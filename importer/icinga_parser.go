@@ -0,0 +1,661 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// icingaLambda is the (unevaluated) source of a function expression
+type icingaLambda string
+
+// icingaDictionary is an insertion ordered map of Icinga values
+type icingaDictionary struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newIcingaDictionary() *icingaDictionary {
+	result := &icingaDictionary{
+		keys:   []string{},
+		values: map[string]interface{}{},
+	}
+
+	return result
+}
+
+func (d *icingaDictionary) Get(key string) (interface{}, bool) {
+	v, ok := d.values[key]
+	return v, ok
+}
+
+func (d *icingaDictionary) Set(key string, value interface{}) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+
+	d.values[key] = value
+}
+
+// Keys returns the dictionary keys in lexical order
+func (d *icingaDictionary) Keys() []string {
+	keys := make([]string, len(d.keys))
+	copy(keys, d.keys)
+	sort.Strings(keys)
+
+	return keys
+}
+
+type icingaExpr interface{}
+
+type icingaLiteral struct {
+	value interface{}
+}
+
+type icingaConstRef struct {
+	name string
+	line int
+}
+
+type icingaBinary struct {
+	left  icingaExpr
+	right icingaExpr
+	line  int
+}
+
+type icingaArray []icingaExpr
+
+type icingaDict []*icingaStatement
+
+// icingaStatement is either an import or an assignment
+type icingaStatement struct {
+	line  int
+	imp   icingaExpr
+	path  []string
+	op    string
+	value icingaExpr
+}
+
+type icingaObject struct {
+	typ      string
+	name     string
+	template bool
+	source   string
+	line     int
+	body     []*icingaStatement
+}
+
+type icingaParser struct {
+	lexer   *icingaLexer
+	token   icingaToken
+	objects []*icingaObject
+	consts  []*icingaStatement
+}
+
+func parseIcinga(source, input string) (*icingaParser, error) {
+	p := &icingaParser{
+		lexer: newIcingaLexer(source, input),
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	for p.token.typ != icingaTokenEOF {
+		if err := p.parseTopLevel(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (p *icingaParser) advance() (err error) {
+	p.token, err = p.lexer.next()
+	return
+}
+
+func (p *icingaParser) errorf(format string, args ...interface{}) error {
+	return p.lexer.errorf(p.token.line, format, args...)
+}
+
+func (p *icingaParser) isPunct(s string) bool {
+	return p.token.typ == icingaTokenPunct && p.token.text == s
+}
+
+func (p *icingaParser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected '%s', got %s", s, p.token)
+	}
+
+	return p.advance()
+}
+
+func (p *icingaParser) expect(typ icingaTokenType, what string) (icingaToken, error) {
+	t := p.token
+	if t.typ != typ {
+		return t, p.errorf("expected %s, got %s", what, t)
+	}
+
+	return t, p.advance()
+}
+
+func (p *icingaParser) parseTopLevel() error {
+	if p.isPunct(";") {
+		return p.advance()
+	}
+
+	t, err := p.expect(icingaTokenIdent, "statement")
+	if err != nil {
+		return err
+	}
+
+	switch t.text {
+	case "object", "template":
+		return p.parseObject(t)
+	case "const":
+		name, err := p.expect(icingaTokenIdent, "constant name")
+		if err != nil {
+			return err
+		}
+
+		if err := p.expectPunct("="); err != nil {
+			return err
+		}
+
+		value, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+
+		p.consts = append(p.consts, &icingaStatement{line: t.line, path: []string{name.text}, op: "=", value: value})
+		return nil
+	case "include", "include_recursive", "include_zones":
+		// include directives are not followed; the files need to
+		// be provided explicitly
+		for p.token.typ == icingaTokenString || p.isPunct("<") || p.isPunct(">") || p.token.typ == icingaTokenIdent && p.token.line == t.line {
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "apply":
+		return p.skipBlock()
+	}
+
+	return p.lexer.errorf(t.line, "unsupported statement '%s'", t.text)
+}
+
+// skipBlock discards all tokens up to and including the next block
+func (p *icingaParser) skipBlock() error {
+	for !p.isPunct("{") {
+		if p.token.typ == icingaTokenEOF {
+			return p.errorf("expected '{', got %s", p.token)
+		}
+
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	depth := 0
+	for {
+		if p.token.typ == icingaTokenEOF {
+			return p.errorf("unterminated block")
+		}
+
+		if p.isPunct("{") {
+			depth++
+		} else if p.isPunct("}") {
+			depth--
+		}
+
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *icingaParser) parseObject(kind icingaToken) error {
+	typ, err := p.expect(icingaTokenIdent, "object type")
+	if err != nil {
+		return err
+	}
+
+	name, err := p.expect(icingaTokenString, "object name")
+	if err != nil {
+		return err
+	}
+
+	if typ.text != "CheckCommand" {
+		return p.skipBlock()
+	}
+
+	// skip modifiers such as ignore_on_error or use(...)
+	for !p.isPunct("{") {
+		if p.token.typ == icingaTokenEOF {
+			return p.errorf("expected '{', got %s", p.token)
+		}
+
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	body, err := p.parseBlock()
+	if err != nil {
+		return err
+	}
+
+	p.objects = append(p.objects, &icingaObject{
+		typ:      typ.text,
+		name:     name.text,
+		template: kind.text == "template",
+		source:   p.lexer.source,
+		line:     kind.line,
+		body:     body,
+	})
+
+	return nil
+}
+
+// parseBlock parses the statements enclosed in curly braces
+func (p *icingaParser) parseBlock() ([]*icingaStatement, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+
+	result := []*icingaStatement{}
+	for !p.isPunct("}") {
+		if p.isPunct(";") || p.isPunct(",") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		s, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, s)
+	}
+
+	return result, p.advance()
+}
+
+func (p *icingaParser) parseStatement() (*icingaStatement, error) {
+	result := &icingaStatement{line: p.token.line}
+
+	if p.token.typ == icingaTokenIdent && p.token.text == "import" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		imp, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		result.imp = imp
+		return result, nil
+	}
+
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isPunct("=") && !p.isPunct("+=") {
+		return nil, p.errorf("expected assignment, got %s", p.token)
+	}
+
+	result.path = path
+	result.op = p.token.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	result.value, err = p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (p *icingaParser) parsePath() ([]string, error) {
+	if p.token.typ != icingaTokenIdent && p.token.typ != icingaTokenString {
+		return nil, p.errorf("expected attribute name, got %s", p.token)
+	}
+
+	result := []string{p.token.text}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.isPunct("."):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			t, err := p.expect(icingaTokenIdent, "attribute name")
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, t.text)
+		case p.isPunct("["):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			t, err := p.expect(icingaTokenString, "attribute name")
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+
+			result = append(result, t.text)
+		default:
+			return result, nil
+		}
+	}
+}
+
+func (p *icingaParser) parseExpr() (icingaExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isPunct("+") {
+		line := p.token.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &icingaBinary{left: left, right: right, line: line}
+	}
+
+	return left, nil
+}
+
+func (p *icingaParser) parseTerm() (icingaExpr, error) {
+	t := p.token
+
+	switch t.typ {
+	case icingaTokenString:
+		return &icingaLiteral{value: t.text}, p.advance()
+	case icingaTokenNumber:
+		return &icingaLiteral{value: t.value}, p.advance()
+	case icingaTokenLambda:
+		return &icingaLiteral{value: icingaLambda(t.text)}, p.advance()
+	case icingaTokenIdent:
+		switch t.text {
+		case "true":
+			return &icingaLiteral{value: true}, p.advance()
+		case "false":
+			return &icingaLiteral{value: false}, p.advance()
+		case "null":
+			return &icingaLiteral{value: nil}, p.advance()
+		}
+
+		return &icingaConstRef{name: t.text, line: t.line}, p.advance()
+	case icingaTokenPunct:
+		switch t.text {
+		case "-":
+			// negative numeric literals, e.g. argument orders
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			if p.token.typ != icingaTokenNumber {
+				return nil, p.errorf("unsupported expression %s", t)
+			}
+
+			return &icingaLiteral{value: -p.token.value}, p.advance()
+		case "(":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			return expr, p.expectPunct(")")
+		case "[":
+			return p.parseArray()
+		case "{":
+			body, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+
+			return icingaDict(body), nil
+		}
+	}
+
+	return nil, p.errorf("unsupported expression %s", t)
+}
+
+func (p *icingaParser) parseArray() (icingaExpr, error) {
+	if err := p.expectPunct("["); err != nil {
+		return nil, err
+	}
+
+	result := icingaArray{}
+	for !p.isPunct("]") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, expr)
+
+		if !p.isPunct(",") {
+			break
+		}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, p.expectPunct("]")
+}
+
+// icingaEvaluator resolves expressions using a set of constants
+type icingaEvaluator struct {
+	source string
+	consts map[string]interface{}
+}
+
+func (e *icingaEvaluator) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", e.source, line, fmt.Sprintf(format, args...))
+}
+
+func (e *icingaEvaluator) eval(expr icingaExpr) (interface{}, error) {
+	switch x := expr.(type) {
+	case *icingaLiteral:
+		return x.value, nil
+	case *icingaConstRef:
+		v, ok := e.consts[x.name]
+		if !ok {
+			return nil, e.errorf(x.line, "unknown constant '%s'", x.name)
+		}
+
+		return copyIcingaValue(v), nil
+	case *icingaBinary:
+		left, err := e.eval(x.left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := e.eval(x.right)
+		if err != nil {
+			return nil, err
+		}
+
+		return e.add(x.line, left, right)
+	case icingaArray:
+		result := make([]interface{}, len(x))
+		for i, item := range x {
+			v, err := e.eval(item)
+			if err != nil {
+				return nil, err
+			}
+
+			result[i] = v
+		}
+
+		return result, nil
+	case icingaDict:
+		result := newIcingaDictionary()
+		for _, s := range x {
+			if err := e.apply(result, s); err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%s: unsupported expression %T", e.source, expr)
+}
+
+// apply evaluates the given assignment and stores the result in d
+func (e *icingaEvaluator) apply(d *icingaDictionary, s *icingaStatement) error {
+	if s.imp != nil {
+		return e.errorf(s.line, "import is not supported in this context")
+	}
+
+	value, err := e.eval(s.value)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range s.path[:len(s.path)-1] {
+		v, ok := d.Get(key)
+		next, isDict := v.(*icingaDictionary)
+		if !ok || v == nil {
+			next = newIcingaDictionary()
+			d.Set(key, next)
+		} else if !isDict {
+			return e.errorf(s.line, "attribute '%s' is not a dictionary", key)
+		}
+
+		d = next
+	}
+
+	key := s.path[len(s.path)-1]
+	if s.op == "+=" {
+		if old, ok := d.Get(key); ok && old != nil {
+			if value, err = e.add(s.line, old, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	d.Set(key, value)
+
+	return nil
+}
+
+func (e *icingaEvaluator) add(line int, left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case string:
+		switch r := right.(type) {
+		case string:
+			return l + r, nil
+		case float64:
+			return l + formatIcingaNumber(r), nil
+		}
+	case float64:
+		switch r := right.(type) {
+		case float64:
+			return l + r, nil
+		case string:
+			return formatIcingaNumber(l) + r, nil
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok {
+			return append(l, r...), nil
+		}
+	case *icingaDictionary:
+		if r, ok := right.(*icingaDictionary); ok {
+			for _, k := range r.keys {
+				l.Set(k, r.values[k])
+			}
+
+			return l, nil
+		}
+	}
+
+	return nil, e.errorf(line, "operator + cannot be applied to %s and %s", icingaTypeName(left), icingaTypeName(right))
+}
+
+func icingaTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "Empty"
+	case string:
+		return "String"
+	case float64:
+		return "Number"
+	case bool:
+		return "Boolean"
+	case []interface{}:
+		return "Array"
+	case *icingaDictionary:
+		return "Dictionary"
+	case icingaLambda:
+		return "Function"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func formatIcingaNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// copyIcingaValue creates a deep copy of arrays and dictionaries
+func copyIcingaValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(x))
+		for i, item := range x {
+			result[i] = copyIcingaValue(item)
+		}
+
+		return result
+	case *icingaDictionary:
+		result := newIcingaDictionary()
+		for _, k := range x.keys {
+			result.Set(k, copyIcingaValue(x.values[k]))
+		}
+
+		return result
+	}
+
+	return v
+}
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIcingaLexer(t *testing.T) {
+	type testCase struct {
+		have      string
+		want      []string
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"comments": testCase{
+			have: "# shell\n// line\n/* block\n */ value",
+			want: []string{"'value'"},
+		},
+		"strings": testCase{
+			have: `"a\"b\tc" {{{multi
+line}}}`,
+			want: []string{`"a\"b\tc"`, `"multi\nline"`},
+		},
+		"durations": testCase{
+			have: "1m 30s 500ms 2h",
+			want: []string{"'1m'", "'30s'", "'500ms'", "'2h'"},
+		},
+		"lambda": testCase{
+			have: `{{ if (macro("$x$") == "}}") { return true } }} =`,
+			want: []string{"function expression", "'='"},
+		},
+		"punctuation": testCase{
+			have: "+= [ ] == =",
+			want: []string{"'+='", "'['", "']'", "'=='", "'='"},
+		},
+		"unterminated string": testCase{
+			have:      `"open`,
+			wantError: true,
+		},
+		"unterminated lambda": testCase{
+			have:      `{{ return true }`,
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			lexer := newIcingaLexer("test.conf", tc.have)
+			got := []string{}
+
+			for {
+				token, err := lexer.next()
+				if tc.wantError && err != nil {
+					return
+				}
+
+				assert.NilError(t, err)
+				if token.typ == icingaTokenEOF {
+					break
+				}
+
+				got = append(got, token.String())
+			}
+
+			assert.Assert(t, !tc.wantError, "expected an error")
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestIcingaNumberValue(t *testing.T) {
+	lexer := newIcingaLexer("test.conf", "1m 1.5s 250ms 1d")
+	want := []float64{60, 1.5, 0.25, 86400}
+
+	for _, w := range want {
+		token, err := lexer.next()
+		assert.NilError(t, err)
+		assert.Equal(t, w, token.value)
+	}
+}
+
+func TestParseIcinga(t *testing.T) {
+	type testCase struct {
+		have        string
+		wantObjects []string
+		wantConsts  []string
+		wantError   string
+	}
+
+	testCases := map[string]testCase{
+		"objects": testCase{
+			have: `
+const Answer = 42
+template CheckCommand "base" { }
+object CheckCommand "check" { import "base" }
+object Host "ignored" { address = "127.0.0.1" }
+apply Service "ignored" { assign where host.name == "localhost" }
+include <itl>
+`,
+			wantObjects: []string{"base", "check"},
+			wantConsts:  []string{"Answer"},
+		},
+		"assignment": testCase{
+			have: `object CheckCommand "check" {
+  command = [ PluginDir + "/check_dummy" ]
+  arguments += {
+    "-a" = { value = "$a$"; order = -1 }
+  }
+  vars.a = 1m
+  vars["b"] = true
+}`,
+			wantObjects: []string{"check"},
+			wantConsts:  []string{},
+		},
+		"unsupported statement": testCase{
+			have:      `function f() { }`,
+			wantError: "test.conf:1: unsupported statement 'function'",
+		},
+		"missing assignment": testCase{
+			have:      "object CheckCommand \"check\" {\n  command\n}",
+			wantError: "test.conf:3: expected assignment, got '}'",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, err := parseIcinga("test.conf", tc.have)
+
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+
+			objects := []string{}
+			for _, o := range got.objects {
+				objects = append(objects, o.name)
+			}
+			consts := []string{}
+			for _, c := range got.consts {
+				consts = append(consts, c.path[0])
+			}
+
+			assert.DeepEqual(t, tc.wantObjects, objects)
+			assert.DeepEqual(t, tc.wantConsts, consts)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+const icingaCommands = `
+template CheckCommand "ping-common" {
+	command = [ PluginDir + "/check_ping" ]
+
+	arguments = {
+		"-H" = "$ping_address$"
+		"-w" = {
+			value = "$ping_wrta$,$ping_wpl$%"
+			description = "warning threshold pair"
+		}
+		"-p" = {
+			value = "$ping_packets$"
+			set_if = {{ macro("$ping_packets$") != "" }}
+		}
+	}
+
+	vars.ping_wrta = 100
+	vars.ping_wpl = 5
+}
+
+object CheckCommand "ping4" {
+	import "ping-common"
+
+	arguments += {
+		"-4" = {
+			set_if = true
+			order = -1
+		}
+	}
+
+	vars.ping_address = "$address$"
+	timeout = 1m
+}
+
+object CheckCommand "disk" {
+	command = [ PluginDir + "/check_disk", "-E" ]
+
+	arguments = {
+		"--partition" = {
+			value = "$disk_partitions$"
+			repeat_key = true
+			required = true
+		}
+		"--units" = {
+			set_if = "$disk_units$"
+			skip_key = false
+		}
+		"--all" = { }
+	}
+
+	vars.disk_partitions = [ "/", "/var$$" ]
+	env.LC_ALL = "C"
+	env.TARGET = "$disk_target$"
+}
+
+object CheckCommand "loop" {
+	import "loop"
+}
+`
+
+func TestIcingaImporter(t *testing.T) {
+	subject := NewIcingaImporter(map[string]string{
+		"PluginDir": "/opt/plugins",
+	})
+	err := subject.Parse("commands.conf", strings.NewReader(icingaCommands))
+	assert.NilError(t, err)
+
+	got, warnings := subject.Modules()
+
+	want := map[string]config.Module{
+		"ping4": config.Module{
+			Command: "/opt/plugins/check_ping",
+			Timeout: config.NumberDuration(time.Minute),
+			Arguments: map[string]config.Argument{
+				"-4": config.Argument{
+					Condition: "true",
+					Order:     -1,
+				},
+				"-H": config.Argument{
+					Value: config.LazyArray{"{{ .Vars.ping_address | lines }}"},
+				},
+				"-w": config.Argument{
+					Value: config.LazyArray{`{{ .Vars.ping_wrta | join " " }},{{ .Vars.ping_wpl | join " " }}%`},
+				},
+			},
+			Variables: map[string]config.Variable{
+				"ping_address": config.Variable{Value: config.LazyArray{""}},
+				"ping_wrta":    config.Variable{Value: config.LazyArray{"100"}},
+				"ping_wpl":     config.Variable{Value: config.LazyArray{"5"}},
+			},
+			Environment: map[string]string{},
+		},
+		"disk": config.Module{
+			Command: "/opt/plugins/check_disk",
+			Arguments: map[string]config.Argument{
+				"command[1]": config.Argument{
+					Value:   config.LazyArray{"-E"},
+					Order:   -999,
+					SkipKey: "true",
+				},
+				"--partition": config.Argument{
+					Value:     config.LazyArray{"{{ .Vars.disk_partitions | lines }}"},
+					RepeatKey: "true",
+					Required:  "true",
+				},
+				"--units": config.Argument{
+					Condition: `{{ .Vars.disk_units | join "" }}`,
+					SkipKey:   "false",
+				},
+				"--all": config.Argument{
+					Condition: "true",
+				},
+			},
+			Variables: map[string]config.Variable{
+				"disk_partitions": config.Variable{Value: config.LazyArray{"/", "/var$"}},
+				"disk_units":      config.Variable{Value: config.LazyArray{""}},
+			},
+			Environment: map[string]string{
+				"LC_ALL": "C",
+			},
+		},
+	}
+	wantWarnings := []string{
+		`commands.conf:35: CheckCommand "disk": environment "TARGET": only static values are supported`,
+		`commands.conf:56: CheckCommand "loop" imports itself`,
+		`commands.conf:21: CheckCommand "ping4": argument "-p": unsupported set_if of type Function`,
+		`commands.conf:21: CheckCommand "ping4": variable "ping_address": runtime macros are not supported in defaults`,
+	}
+
+	gotWarnings := make([]string, len(warnings))
+	for i, w := range warnings {
+		gotWarnings[i] = w.Error()
+	}
+
+	assert.DeepEqual(t, want, got)
+	assert.DeepEqual(t, wantWarnings, gotWarnings)
+}
+
+func TestIcingaImporterDuplicate(t *testing.T) {
+	subject := NewIcingaImporter(nil)
+	err := subject.Parse("a.conf", strings.NewReader(`object CheckCommand "dummy" { command = "check_dummy" }`))
+	assert.NilError(t, err)
+
+	err = subject.Parse("b.conf", strings.NewReader(`object CheckCommand "dummy" { command = "check_dummy" }`))
+	assert.Error(t, err, `b.conf:1: CheckCommand "dummy" has already been defined in a.conf:1`)
+}
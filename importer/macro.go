@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"strings"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+const (
+	// pipeline for values consisting of a single macro; every
+	// item of an array variable becomes an individual value
+	valuePipeline = "lines"
+	// pipeline for conditions consisting of a single macro
+	conditionPipeline = `join ""`
+	// pipeline for macros embedded in other text
+	embeddedPipeline = `join " "`
+)
+
+// sanitizeVariable replaces characters which are not
+// allowed in template field names
+func sanitizeVariable(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+
+	return string(b)
+}
+
+// hasMacros reports whether the given string contains any runtime macros
+func hasMacros(s string) bool {
+	return len(config.SplitMacros(s)) > 1
+}
+
+// unescapeMacros replaces escaped dollar signs in strings without macros
+func unescapeMacros(s string) string {
+	parts := config.SplitMacros(s)
+	if len(parts) != 1 {
+		return s
+	}
+
+	return parts[0]
+}
+
+// macroTemplate translates runtime macros ($name$) in the given string into
+// template expressions. A string consisting of a single macro is rendered
+// using the given pipeline. Macro names are converted into variable names
+// using the given function. The referenced variable names are returned
+// alongside the template.
+func macroTemplate(s, pipeline string, variable func(string) string) (string, []string) {
+	parts := config.SplitMacros(s)
+	if len(parts) == 1 {
+		return escapeTemplate(parts[0]), nil
+	}
+
+	if len(parts) == 3 && parts[0] == "" && parts[2] == "" {
+		v := variable(parts[1])
+		return "{{ .Vars." + v + " | " + pipeline + " }}", []string{v}
+	}
+
+	buf := &strings.Builder{}
+	vars := []string{}
+	for i, p := range parts {
+		if i%2 == 0 {
+			buf.WriteString(escapeTemplate(p))
+			continue
+		}
+
+		v := variable(p)
+		vars = append(vars, v)
+		buf.WriteString("{{ .Vars." + v + " | " + embeddedPipeline + " }}")
+	}
+
+	return buf.String(), vars
+}
+
+// escapeTemplate quotes template delimiters in literal text
+func escapeTemplate(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+
+	return strings.ReplaceAll(s, "{{", `{{ "{{" }}`)
+}
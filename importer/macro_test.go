@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSanitizeVariable(t *testing.T) {
+	testCases := map[string]string{
+		"ping_address":    "ping_address",
+		"address6":        "address6",
+		"disk-partitions": "disk_partitions",
+		"host.vars.port":  "host_vars_port",
+		"1st":             "_1st",
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.Equal(t, want, sanitizeVariable(have))
+		})
+	}
+}
+
+func TestMacroTemplate(t *testing.T) {
+	type testCase struct {
+		have     string
+		pipeline string
+		want     string
+		wantVars []string
+	}
+
+	testCases := map[string]testCase{
+		"literal": testCase{
+			have:     "-4",
+			pipeline: valuePipeline,
+			want:     "-4",
+		},
+		"escaped dollar": testCase{
+			have:     "$$HOME",
+			pipeline: valuePipeline,
+			want:     "$HOME",
+		},
+		"unterminated": testCase{
+			have:     "$price",
+			pipeline: valuePipeline,
+			want:     "$price",
+		},
+		"unterminated after escaped dollar": testCase{
+			have:     "$$HOME/$price",
+			pipeline: valuePipeline,
+			want:     "$HOME/$price",
+		},
+		"invalid name": testCase{
+			have:     "$5 or 10$",
+			pipeline: valuePipeline,
+			want:     "$5 or 10$",
+		},
+		"invalid name before macro": testCase{
+			have:     "a b$ c $ping_wpl$",
+			pipeline: valuePipeline,
+			want:     `a b$ c {{ .Vars.ping_wpl | join " " }}`,
+			wantVars: []string{"ping_wpl"},
+		},
+		"template delimiters": testCase{
+			have:     "{{ .Vars }}",
+			pipeline: valuePipeline,
+			want:     `{{ "{{" }} .Vars }}`,
+		},
+		"single value": testCase{
+			have:     "$ping_address$",
+			pipeline: valuePipeline,
+			want:     "{{ .Vars.ping_address | lines }}",
+			wantVars: []string{"ping_address"},
+		},
+		"single condition": testCase{
+			have:     "$ping_ipv4$",
+			pipeline: conditionPipeline,
+			want:     `{{ .Vars.ping_ipv4 | join "" }}`,
+			wantVars: []string{"ping_ipv4"},
+		},
+		"prefixed": testCase{
+			have:     "$host.vars.ssh_port$",
+			pipeline: valuePipeline,
+			want:     "{{ .Vars.ssh_port | lines }}",
+			wantVars: []string{"ssh_port"},
+		},
+		"embedded": testCase{
+			have:     "$ping_wrta$,$ping_wpl$%",
+			pipeline: valuePipeline,
+			want:     `{{ .Vars.ping_wrta | join " " }},{{ .Vars.ping_wpl | join " " }}%`,
+			wantVars: []string{"ping_wrta", "ping_wpl"},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, gotVars := macroTemplate(tc.have, tc.pipeline, icingaVariable)
+
+			assert.Equal(t, tc.want, got)
+			assert.DeepEqual(t, tc.wantVars, gotVars)
+		})
+	}
+}
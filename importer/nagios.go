@@ -0,0 +1,390 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+// nagiosShellCharacters cause Nagios to run a command line using
+// a shell, which has no equivalent in the module configuration
+const nagiosShellCharacters = "|&;<>`"
+
+var errNagiosShell = errors.New("shell syntax is not supported")
+
+// nagiosMacroPrefixes map macro name prefixes to variable name prefixes;
+// custom object variables are listed before the regular object macros
+var nagiosMacroPrefixes = [][2]string{
+	{"_HOST", "host_"},
+	{"_SERVICE", "service_"},
+	{"HOST", "host_"},
+	{"SERVICE", "service_"},
+}
+
+// nagiosVariable returns the variable name for the given macro name,
+// e.g. ARG1 becomes arg1 and HOSTADDRESS becomes host_address
+func nagiosVariable(name string) string {
+	for _, p := range nagiosMacroPrefixes {
+		if strings.HasPrefix(name, p[0]) && len(name) > len(p[0]) {
+			name = p[1] + name[len(p[0]):]
+			break
+		}
+	}
+
+	return sanitizeVariable(strings.ToLower(name))
+}
+
+type nagiosCommand struct {
+	name   string
+	line   string
+	source string
+	lineNo int
+}
+
+// NagiosImporter converts Nagios command definitions into modules
+type NagiosImporter struct {
+	resources map[string]string
+	commands  map[string]*nagiosCommand
+	warnings  []error
+}
+
+// NewNagiosImporter creates a new importer without any resource macros
+func NewNagiosImporter() *NagiosImporter {
+	result := &NagiosImporter{
+		resources: map[string]string{},
+		commands:  map[string]*nagiosCommand{},
+		warnings:  []error{},
+	}
+
+	return result
+}
+
+// ParseResources reads $USERn$ macro definitions from the given source
+// (usually resource.cfg).
+func (i *NagiosImporter) ParseResources(source string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || len(name) < 3 || name[0] != '$' || name[len(name)-1] != '$' {
+			return fmt.Errorf("%s:%d: invalid resource definition", source, lineNo)
+		}
+
+		i.resources[name[1:len(name)-1]] = strings.TrimSpace(value)
+	}
+
+	return scanner.Err()
+}
+
+// Parse reads the command definitions from the given object configuration
+// source (usually commands.cfg). Other object types are ignored.
+func (i *NagiosImporter) Parse(source string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	var object string
+	var command *nagiosCommand
+
+	for scanner.Scan() {
+		lineNo++
+		line := nagiosStripComment(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if object == "" {
+			rest, ok := strings.CutPrefix(line, "define")
+			rest = strings.TrimSpace(rest)
+			if !ok || !strings.HasSuffix(rest, "{") {
+				return fmt.Errorf("%s:%d: expected object definition", source, lineNo)
+			}
+
+			object = strings.TrimSpace(strings.TrimSuffix(rest, "{"))
+			if object == "" {
+				return fmt.Errorf("%s:%d: missing object type", source, lineNo)
+			}
+
+			if object == "command" {
+				command = &nagiosCommand{source: source, lineNo: lineNo}
+			}
+
+			continue
+		}
+
+		if line == "}" {
+			if err := i.define(command); err != nil {
+				return err
+			}
+
+			object = ""
+			command = nil
+			continue
+		}
+
+		if command == nil {
+			continue
+		}
+
+		key, value := line, ""
+		if j := strings.IndexAny(line, " \t"); j >= 0 {
+			key, value = line[:j], line[j+1:]
+		}
+
+		switch key {
+		case "command_name":
+			command.name = strings.TrimSpace(value)
+		case "command_line":
+			command.line = strings.TrimSpace(value)
+		default:
+			i.warnings = append(i.warnings, fmt.Errorf("%s:%d: unsupported command directive '%s'", source, lineNo, key))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if object != "" {
+		return fmt.Errorf("%s:%d: unterminated %s definition", source, lineNo, object)
+	}
+
+	return nil
+}
+
+func (i *NagiosImporter) define(command *nagiosCommand) error {
+	if command == nil {
+		return nil
+	}
+
+	if command.name == "" {
+		return fmt.Errorf("%s:%d: command definition is missing command_name", command.source, command.lineNo)
+	}
+
+	if prev, ok := i.commands[command.name]; ok {
+		return fmt.Errorf("%s:%d: command %q has already been defined in %s:%d", command.source, command.lineNo, command.name, prev.source, prev.lineNo)
+	}
+
+	i.commands[command.name] = command
+
+	return nil
+}
+
+// Modules converts all parsed command definitions into modules. Commands
+// which can not be represented are reported as warning and left out.
+func (i *NagiosImporter) Modules() (map[string]config.Module, []error) {
+	warnings := append([]error{}, i.warnings...)
+	modules := map[string]config.Module{}
+
+	names := make([]string, 0, len(i.commands))
+	for name := range i.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := &nagiosConverter{command: i.commands[name], resources: i.resources, warnings: &warnings}
+		if module, ok := c.module(); ok {
+			modules[name] = module
+		}
+	}
+
+	return modules, warnings
+}
+
+type nagiosConverter struct {
+	command   *nagiosCommand
+	resources map[string]string
+	warnings  *[]error
+	vars      []string
+}
+
+func (c *nagiosConverter) warnf(format string, args ...interface{}) {
+	*c.warnings = append(*c.warnings, fmt.Errorf("%s:%d: command %q: %s", c.command.source, c.command.lineNo, c.command.name, fmt.Sprintf(format, args...)))
+}
+
+// template substitutes resource macros and converts
+// all other macros into template expressions
+func (c *nagiosConverter) template(s string) string {
+	parts := config.SplitMacros(s)
+	buf := &strings.Builder{}
+	for n, p := range parts {
+		if n%2 == 0 {
+			buf.WriteString(strings.ReplaceAll(p, "$", "$$"))
+		} else if v, ok := c.resources[p]; ok {
+			buf.WriteString(strings.ReplaceAll(v, "$", "$$"))
+		} else {
+			if strings.HasPrefix(p, "USER") {
+				c.warnf("undefined resource macro $%s$", p)
+			}
+
+			buf.WriteString("$" + p + "$")
+		}
+	}
+
+	t, vars := macroTemplate(buf.String(), embeddedPipeline, nagiosVariable)
+	c.vars = append(c.vars, vars...)
+
+	return t
+}
+
+func (c *nagiosConverter) module() (config.Module, bool) {
+	module := config.Module{
+		Arguments: map[string]config.Argument{},
+		Variables: map[string]config.Variable{},
+	}
+
+	if c.command.line == "" {
+		c.warnf("missing command_line")
+		return module, false
+	}
+
+	argv, err := splitNagiosCommandLine(c.command.line)
+	if err != nil {
+		c.warnf("%s", err)
+		return module, false
+	}
+
+	module.Command = c.template(argv[0])
+	if len(c.vars) > 0 {
+		c.warnf("runtime macros in the command path are not supported")
+		return module, false
+	}
+
+	for n := 1; n < len(argv); n++ {
+		token := argv[n]
+		order := len(module.Arguments) + 1
+		arg := config.Argument{
+			Order: order,
+		}
+
+		if !isNagiosFlag(token) {
+			arg.Value = config.LazyArray{c.template(token)}
+			arg.SkipKey = "true"
+			module.Arguments[fmt.Sprintf("command[%d]", order)] = arg
+			continue
+		}
+
+		arg.Key = token
+		if key, value, ok := strings.Cut(token, "="); ok && strings.HasPrefix(key, "--") {
+			arg.Key = key
+			arg.Separator = "="
+			arg.Value = config.LazyArray{c.template(value)}
+		} else if n+1 < len(argv) && !isNagiosFlag(argv[n+1]) {
+			n++
+			arg.Value = config.LazyArray{c.template(argv[n])}
+		} else {
+			arg.Condition = "true"
+		}
+
+		// repeated flags require unique map keys
+		name := arg.Key
+		if _, ok := module.Arguments[name]; ok {
+			name = fmt.Sprintf("%s[%d]", arg.Key, order)
+		} else {
+			arg.Key = ""
+		}
+
+		module.Arguments[name] = arg
+	}
+
+	for _, v := range c.vars {
+		module.Variables[v] = config.Variable{Value: config.LazyArray{""}}
+	}
+
+	return module, true
+}
+
+// isNagiosFlag reports whether the given command line token
+// is an option rather than a (possibly negative numeric) value
+func isNagiosFlag(s string) bool {
+	return len(s) > 1 && s[0] == '-' && !(s[1] >= '0' && s[1] <= '9' || s[1] == '.')
+}
+
+// nagiosStripComment removes comments and surrounding whitespace.
+// Semicolons can be escaped using a backslash.
+func nagiosStripComment(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+
+	buf := &strings.Builder{}
+	for n := 0; n < len(line); n++ {
+		if line[n] == '\\' && n+1 < len(line) && line[n+1] == ';' {
+			buf.WriteByte(';')
+			n++
+		} else if line[n] == ';' {
+			break
+		} else {
+			buf.WriteByte(line[n])
+		}
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+// splitNagiosCommandLine splits the command line into its arguments,
+// honoring single and double quotes as well as backslash escapes.
+func splitNagiosCommandLine(line string) ([]string, error) {
+	result := []string{}
+	buf := &strings.Builder{}
+	inToken := false
+	var quote byte
+
+	for n := 0; n < len(line); n++ {
+		c := line[n]
+
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == '\'':
+			buf.WriteByte(c)
+		case c == '\\' && n+1 < len(line):
+			n++
+			buf.WriteByte(line[n])
+			inToken = true
+		case quote != 0:
+			buf.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inToken = true
+		case c == ' ' || c == '\t':
+			if inToken {
+				result = append(result, buf.String())
+				buf.Reset()
+				inToken = false
+			}
+		case strings.IndexByte(nagiosShellCharacters, c) >= 0:
+			return nil, errNagiosShell
+		default:
+			buf.WriteByte(c)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+
+	if inToken {
+		result = append(result, buf.String())
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("empty command line")
+	}
+
+	return result, nil
+}
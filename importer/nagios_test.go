@@ -0,0 +1,213 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+const nagiosResources = `
+# plugin location
+$USER1$=/usr/lib/nagios/plugins
+$USER3$=s3cr$t
+`
+
+const nagiosCommands = `
+# 'check_http' command definition
+define command {
+	command_name    check_http
+	command_line    $USER1$/check_http -I $HOSTADDRESS$ -u $ARG1$ --sni -e 200 ; inline comment
+}
+
+define host {
+	host_name       localhost
+	address         127.0.0.1
+}
+
+define command{
+	command_name    check_snmp
+	command_line    $USER1$/check_snmp -H $HOSTADDRESS$ -C $USER3$ -o "$ARG1$" -o $ARG2$ --label='uptime is' -w -5:
+}
+
+define command {
+	command_name    check_pipe
+	command_line    /bin/printf "%s" $ARG1$ | logger
+}
+
+define command {
+	command_name    check_custom
+	command_line    $USER2$/check_custom $_HOSTPORT$ $SERVICEDESC$
+}
+`
+
+func TestNagiosVariable(t *testing.T) {
+	testCases := map[string]string{
+		"ARG1":         "arg1",
+		"HOSTADDRESS":  "host_address",
+		"HOSTADDRESS6": "host_address6",
+		"SERVICEDESC":  "service_desc",
+		"_HOSTPORT":    "host_port",
+		"_SERVICEURL":  "service_url",
+		"TIMET":        "timet",
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.Equal(t, want, nagiosVariable(have))
+		})
+	}
+}
+
+func TestSplitNagiosCommandLine(t *testing.T) {
+	type testCase struct {
+		have      string
+		want      []string
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"plain": testCase{
+			have: "check_dummy  0\t'all good'",
+			want: []string{"check_dummy", "0", "all good"},
+		},
+		"quotes": testCase{
+			have: `check_http -s "\"OK\" it's" -u '$ARG1$'`,
+			want: []string{"check_http", "-s", `"OK" it's`, "-u", "$ARG1$"},
+		},
+		"escapes": testCase{
+			have: `check_disk -p /mnt/my\ disk`,
+			want: []string{"check_disk", "-p", "/mnt/my disk"},
+		},
+		"shell": testCase{
+			have:      "check_dummy 0 > /dev/null",
+			wantError: true,
+		},
+		"unterminated": testCase{
+			have:      `check_dummy "0`,
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, err := splitNagiosCommandLine(tc.have)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestNagiosImporter(t *testing.T) {
+	subject := NewNagiosImporter()
+	err := subject.ParseResources("resource.cfg", strings.NewReader(nagiosResources))
+	assert.NilError(t, err)
+
+	err = subject.Parse("commands.cfg", strings.NewReader(nagiosCommands))
+	assert.NilError(t, err)
+
+	got, warnings := subject.Modules()
+
+	want := map[string]config.Module{
+		"check_http": config.Module{
+			Command: "/usr/lib/nagios/plugins/check_http",
+			Arguments: map[string]config.Argument{
+				"-I": config.Argument{
+					Value: config.LazyArray{`{{ .Vars.host_address | join " " }}`},
+					Order: 1,
+				},
+				"-u": config.Argument{
+					Value: config.LazyArray{`{{ .Vars.arg1 | join " " }}`},
+					Order: 2,
+				},
+				"--sni": config.Argument{
+					Condition: "true",
+					Order:     3,
+				},
+				"-e": config.Argument{
+					Value: config.LazyArray{"200"},
+					Order: 4,
+				},
+			},
+			Variables: map[string]config.Variable{
+				"host_address": config.Variable{Value: config.LazyArray{""}},
+				"arg1":         config.Variable{Value: config.LazyArray{""}},
+			},
+		},
+		"check_snmp": config.Module{
+			Command: "/usr/lib/nagios/plugins/check_snmp",
+			Arguments: map[string]config.Argument{
+				"-H": config.Argument{
+					Value: config.LazyArray{`{{ .Vars.host_address | join " " }}`},
+					Order: 1,
+				},
+				"-C": config.Argument{
+					Value: config.LazyArray{"s3cr$t"},
+					Order: 2,
+				},
+				"-o": config.Argument{
+					Value: config.LazyArray{`{{ .Vars.arg1 | join " " }}`},
+					Order: 3,
+				},
+				"-o[4]": config.Argument{
+					Key:   "-o",
+					Value: config.LazyArray{`{{ .Vars.arg2 | join " " }}`},
+					Order: 4,
+				},
+				"--label": config.Argument{
+					Value:     config.LazyArray{"uptime is"},
+					Order:     5,
+					Separator: "=",
+				},
+				"-w": config.Argument{
+					Value: config.LazyArray{"-5:"},
+					Order: 6,
+				},
+			},
+			Variables: map[string]config.Variable{
+				"host_address": config.Variable{Value: config.LazyArray{""}},
+				"arg1":         config.Variable{Value: config.LazyArray{""}},
+				"arg2":         config.Variable{Value: config.LazyArray{""}},
+			},
+		},
+	}
+	wantWarnings := []string{
+		`commands.cfg:23: command "check_custom": undefined resource macro $USER2$`,
+		`commands.cfg:23: command "check_custom": runtime macros in the command path are not supported`,
+		`commands.cfg:18: command "check_pipe": shell syntax is not supported`,
+	}
+
+	gotWarnings := make([]string, len(warnings))
+	for i, w := range warnings {
+		gotWarnings[i] = w.Error()
+	}
+
+	assert.DeepEqual(t, want, got)
+	assert.DeepEqual(t, wantWarnings, gotWarnings)
+}
+
+func TestNagiosImporterErrors(t *testing.T) {
+	testCases := map[string]string{
+		"unterminated": "define command {\n\tcommand_name check_dummy\n",
+		"missing name": "define command {\n\tcommand_line check_dummy 0\n}\n",
+		"garbage":      "command_name check_dummy\n",
+		"duplicate":    "define command {\n\tcommand_name a\n}\ndefine command {\n\tcommand_name a\n}\n",
+	}
+
+	for ctx, have := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			subject := NewNagiosImporter()
+			err := subject.Parse("commands.cfg", strings.NewReader(have))
+
+			assert.Assert(t, err != nil)
+		})
+	}
+}
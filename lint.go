@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+var lintCommand = kingpin.Command("lint", "Report questionable module definitions in the configuration file.")
+
+// runLint prints the lint warnings of the configuration file;
+// the exit code signals whether any have been found
+func runLint(logger log.Logger) int {
+	c, err := config.LoadConfig(*configFile)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error loading config", "err", err)
+		return 1
+	}
+
+	warnings := c.Lint()
+	for _, w := range warnings {
+		fmt.Println(w.String())
+	}
+
+	if len(warnings) > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
@@ -21,7 +28,11 @@ import (
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
 
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+	monitoring "github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nrpe"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/passive"
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/prober"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/prober/nagios"
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
 )
 
@@ -32,21 +43,55 @@ const (
 
 	healthEndpoint    = "/-/healthy"
 	reloadEndpoint    = "/-/reload"
+	rollbackEndpoint  = "/-/rollback"
 	telemetryEndpoint = "/metrics"
 	configEndpoint    = "/config"
+	schemaEndpoint    = "/config/schema"
+	historyEndpoint   = "/config/history"
 	probeEndpoint     = "/probe"
+	passiveEndpoint   = "/v1/actions/process-check-result"
+
+	// configWatchDebounce is the duration the config files must
+	// remain unchanged before an automatic reload is triggered
+	configWatchDebounce = time.Second
 )
 
 var (
 	sc = config.NewSafeConfig(ident, prometheus.DefaultRegisterer)
 	tc = template.NewFuncMapTemplateCache(template.Functions)
 
-	configFile  = kingpin.Flag("config.file", "Nagios Plugin exporter configuration file.").Default(ident + ".yml").String()
-	configCheck = kingpin.Flag("config.check", "If true validate the config file and then exit.").Default().Bool()
+	runCommand    = kingpin.Command("run", "Run the exporter.").Default()
+	schemaCommand = kingpin.Command("schema", "Print the JSON Schema of the configuration file.")
+
+	configFile          = kingpin.Flag("config.file", "Nagios Plugin exporter configuration file.").Default(ident + ".yml").String()
+	configCheck         = kingpin.Flag("config.check", "If true validate the config file and then exit.").Default().Bool()
+	configWatch         = kingpin.Flag("config.watch", "Reload the config file automatically whenever it or one of its includes changes.").Default().Bool()
+	configWatchInterval = kingpin.Flag("config.watch-interval", "Polling interval of the config files, if file system notifications are not available.").Default("30s").Duration()
+	configStrict        = kingpin.Flag("config.strict", "Reject configurations with modules failing the preflight checks of their plugin executable.").Default().Bool()
+	configHistory       = kingpin.Flag("config.history", "Number of configuration generations to keep for rollbacks.").Default(strconv.Itoa(config.DefaultHistorySize)).Int()
+	configOverlayFile   = kingpin.Flag("config.overlay-file", "File to persist modules changed via the module API (empty to keep them in memory only).").Default("").String()
 
 	webDebug      = kingpin.Flag("web.debug", "Enable the debugging feature for the metrics endpoint").Default().Bool()
+	webPassive    = kingpin.Flag("web.passive", "Enable the Icinga 2 compatible passive check result endpoint").Default().Bool()
+	webModuleAPI  = kingpin.Flag("web.enable-module-api", "Enable adding, replacing and removing modules via the module API. Protect the endpoint using the web configuration (TLS, authentication).").Default().Bool()
 	timeoutOffset = kingpin.Flag("timeout-offset", "Offset to subtract from timeout in seconds.").Default("0.5").Float64()
 
+	passiveFreshness  = kingpin.Flag("passive.freshness", "Duration after which passive check results are considered stale (0 to disable).").Default("10m").Duration()
+	passiveRetention  = kingpin.Flag("passive.retention", "Duration for which stale passive check results are kept before they are dropped.").Default("1h").Duration()
+	passiveMaxObjects = kingpin.Flag("passive.max-objects", "Maximum number of hosts and services with passive check results; results for further objects are rejected (0 to disable).").Default("10000").Int()
+
+	nscaListenAddress = kingpin.Flag("nsca.listen-address", "Address on which to accept NSCA passive check results (empty to disable).").Default("").String()
+	nscaEncryption    = kingpin.Flag("nsca.encryption", "NSCA payload encryption method. One of: [none, xor]").Default("none").String()
+	nscaPasswordFile  = kingpin.Flag("nsca.password-file", "File containing the NSCA encryption password.").Default("").String()
+	nscaMaxPacketAge  = kingpin.Flag("nsca.max-packet-age", "Maximum age of NSCA packets before they are discarded (0 to disable).").Default("30s").Duration()
+
+	nrpeListenAddress  = kingpin.Flag("nrpe.listen-address", "Address on which to answer NRPE queries using the configured modules (empty to disable).").Default("").String()
+	nrpeTLSCertFile    = kingpin.Flag("nrpe.tls-cert-file", "Certificate file for NRPE connections. Connections are unencrypted if omitted.").Default("").String()
+	nrpeTLSKeyFile     = kingpin.Flag("nrpe.tls-key-file", "Key file for NRPE connections.").Default("").String()
+	nrpeAllowedHosts   = kingpin.Flag("nrpe.allowed-hosts", "Comma separated list of addresses and networks allowed to send NRPE queries (empty to allow any).").Default("127.0.0.1,::1").String()
+	nrpeAllowArguments = kingpin.Flag("nrpe.allow-arguments", "Allow NRPE queries to pass arguments to modules declaring nrpe_arguments.").Default().Bool()
+	nrpeCommandTimeout = kingpin.Flag("nrpe.command-timeout", "Maximum execution time of modules run by NRPE queries.").Default("60s").Duration()
+
 	logLevelProber = kingpin.Flag("log.prober", "Log level from probe requests. One of: [debug, info, warn, error, none]").Default("none").String()
 	toolkitFlags   = webflag.AddFlags(kingpin.CommandLine, ":9665")
 
@@ -55,6 +100,11 @@ var (
 		Name:      "module_unknown_total",
 		Help:      "Count of unknown modules requested by probes",
 	})
+	probeRejectedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ident,
+		Name:      "probe_rejected_total",
+		Help:      "Count of probe requests rejected due to invalid input",
+	}, []string{"module", "reason"})
 )
 
 func init() {
@@ -65,41 +115,55 @@ func main() {
 	os.Exit(run())
 }
 
-func parseArgs() log.Logger {
+func parseArgs() (log.Logger, string) {
 	promlogConfig := &promlog.Config{}
 
 	kingpin.CommandLine.UsageWriter(os.Stdout)
 	flag.AddFlags(kingpin.CommandLine, promlogConfig)
 	kingpin.Version(version.Print(name))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
+
+	return promlog.New(promlogConfig), command
+}
 
-	return promlog.New(promlogConfig)
+// reloadResult is the outcome of a reload requested via HTTP
+type reloadResult struct {
+	reload *config.Reload
+	err    error
 }
 
-func watchConfig(reloadCh chan chan error, logger log.Logger) {
+func watchConfig(reloadCh chan chan reloadResult, logger log.Logger) {
 	hup := make(chan os.Signal, 1)
 	signal.Notify(hup, syscall.SIGHUP)
 
+	var changes <-chan struct{}
+	if *configWatch {
+		changes = config.Watch(context.Background(), *configFile, *configWatchInterval, configWatchDebounce, logger)
+	}
+
+	reload := func() reloadResult {
+		r, err := sc.ReloadConfig(*configFile, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Error reloading config", "err", err)
+			return reloadResult{err: err}
+		}
+
+		tc.Flush()
+		level.Info(logger).Log("msg", "Reloaded config file", "generation", r.Generation)
+		return reloadResult{reload: r}
+	}
+
 	go func() {
 		for {
 			select {
 			case <-hup:
-				if err := sc.ReloadConfig(*configFile, logger); err != nil {
-					level.Error(logger).Log("msg", "Error reloading config", "err", err)
-				} else {
-					tc.Flush()
-					level.Info(logger).Log("msg", "Reloaded config file")
-				}
+				reload()
+			case <-changes:
+				level.Info(logger).Log("msg", "Config file change detected")
+				reload()
 			case rc := <-reloadCh:
-				if err := sc.ReloadConfig(*configFile, logger); err != nil {
-					level.Error(logger).Log("msg", "Error reloading config", "err", err)
-					rc <- err
-				} else {
-					tc.Flush()
-					level.Info(logger).Log("msg", "Reloaded config file")
-					rc <- nil
-				}
+				rc <- reload()
 			}
 		}
 	}()
@@ -148,7 +212,7 @@ func healthHandlerFunc() http.HandlerFunc {
 }
 
 func probeHandlerFunc(logger log.Logger, logLevel level.Option) http.HandlerFunc {
-	handler := prober.NewHandler(ident, tc, logger, logLevel, *webDebug, *timeoutOffset)
+	handler := prober.NewHandler(ident, tc, probeRejectedCounter, logger, logLevel, *webDebug, *timeoutOffset)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		sc.ProvideConfig(func(conf *config.Config) {
@@ -177,45 +241,250 @@ func probeHandlerFunc(logger log.Logger, logLevel level.Option) http.HandlerFunc
 
 func configHandlerFunc(logger log.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		moduleName := r.URL.Query().Get("module")
+
 		sc.ProvideConfig(func(conf *config.Config) {
-			c, err := conf.MarshalYAML()
+			if moduleName != "" {
+				module, ok := conf.Modules[moduleName]
+				if !ok {
+					http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusNotFound)
+					return
+				}
+
+				conf = &config.Config{
+					Modules: map[string]config.Module{moduleName: module},
+				}
+			}
+
+			conf = conf.Redacted()
+
+			var c []byte
+			var err error
+			var contentType string
+
+			switch format {
+			case "", "yaml":
+				contentType = "text/plain"
+				c, err = conf.MarshalYAML()
+			case "json":
+				contentType = "application/json"
+				c, err = conf.MarshalJSON()
+			case "icinga":
+				contentType = "text/plain"
+				c, err = conf.MarshalIcinga()
+			default:
+				http.Error(w, fmt.Sprintf("Unsupported format %q", format), http.StatusBadRequest)
+				return
+			}
+
 			if err != nil {
 				level.Warn(logger).Log("msg", "Error marshalling configuration", "err", err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Type", contentType)
 			w.Write(c)
 		})
 	}
 }
 
-func reloadHandlerFunc(reloadCh chan chan error) http.HandlerFunc {
+func schemaHandlerFunc(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := config.MarshalJSONSchema()
+		if err != nil {
+			level.Warn(logger).Log("msg", "Error marshalling configuration schema", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(c)
+	}
+}
+
+func runSchema(logger log.Logger) int {
+	c, err := config.MarshalJSONSchema()
+	if err != nil {
+		level.Error(logger).Log("msg", "Error marshalling configuration schema", "err", err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stdout, string(c))
+
+	return 0
+}
+
+func reloadHandlerFunc(reloadCh chan chan reloadResult, logger log.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
-			rc := make(chan error)
+			rc := make(chan reloadResult)
 			reloadCh <- rc
-			if err := <-rc; err != nil {
-				http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+			result := <-rc
+			if result.err != nil {
+				http.Error(w, fmt.Sprintf("failed to reload config: %s", result.err), http.StatusInternalServerError)
+				return
+			}
+
+			data, err := json.Marshal(result.reload)
+			if err != nil {
+				level.Error(logger).Log("msg", "Unable to render reload result", "err", err)
+				http.Error(w, "Unable to render reload result", http.StatusInternalServerError)
+				return
 			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
 		default:
 			http.Error(w, "POST method expected", http.StatusBadRequest)
 		}
 	}
 }
 
+func rollbackHandlerFunc(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST method expected", http.StatusBadRequest)
+			return
+		}
+
+		generation, err := strconv.Atoi(r.URL.Query().Get("generation"))
+		if err != nil {
+			http.Error(w, "Generation parameter is missing or invalid", http.StatusBadRequest)
+			return
+		}
+
+		result, err := sc.Rollback(generation, logger)
+		if errors.Is(err, config.ErrUnknownGeneration) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			level.Error(logger).Log("msg", "Error rolling back config", "generation", generation, "err", err)
+			http.Error(w, fmt.Sprintf("failed to roll back config: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		tc.Flush()
+		level.Info(logger).Log("msg", "Rolled back config", "generation", result.Generation, "rollback_of", generation)
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to render rollback result", "err", err)
+			http.Error(w, "Unable to render rollback result", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+func historyHandlerFunc(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(sc.History())
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to render config history", "err", err)
+			http.Error(w, "Unable to render config history", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
 func runServer(srvc chan struct{}, logger log.Logger) {
 	srv := &http.Server{}
 
 	go func() {
 		if err := web.ListenAndServe(srv, toolkitFlags, logger); err != nil {
 			level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
-			close(srvc)
+			srvc <- struct{}{}
 		}
 	}()
 }
 
+func runNSCAServer(srvc chan struct{}, store *passive.Store, logger log.Logger) error {
+	method, err := passive.ParseNSCAEncryption(*nscaEncryption)
+	if err != nil {
+		return err
+	}
+
+	var password []byte
+	if *nscaPasswordFile != "" {
+		if password, err = os.ReadFile(*nscaPasswordFile); err != nil {
+			return err
+		}
+
+		password = bytes.TrimRight(password, "\r\n")
+	}
+
+	srv := passive.NewNSCAServer(store, logger, method, password, *nscaMaxPacketAge)
+
+	go func() {
+		level.Info(logger).Log("msg", "Listening for NSCA check results", "address", *nscaListenAddress)
+		if err := srv.ListenAndServe(*nscaListenAddress); err != nil {
+			level.Error(logger).Log("msg", "Error starting NSCA server", "err", err)
+			srvc <- struct{}{}
+		}
+	}()
+
+	return nil
+}
+
+func nrpeHandlerFunc(logger log.Logger, logLevel level.Option) nrpe.HandlerFunc {
+	handler := prober.NewNRPEHandler(tc, probeRejectedCounter, logger, logLevel, *nrpeAllowArguments, *nrpeCommandTimeout)
+
+	return func(ctx context.Context, command string, arguments []string) (result *monitoring.PluginResult, err error) {
+		sc.ProvideConfig(func(conf *config.Config) {
+			module, ok := conf.Modules[command]
+			if !ok {
+				level.Debug(logger).Log("msg", "Unknown module", "module", command)
+				moduleUnknownCounter.Add(1)
+				err = fmt.Errorf("Command '%s' not defined", command)
+
+				return
+			}
+
+			ctx = config.NewContext(ctx, command, &module)
+			result, err = handler.ServeNRPE(ctx, command, arguments)
+		})
+
+		return
+	}
+}
+
+func runNRPEServer(srvc chan struct{}, logger log.Logger, logLevel level.Option) error {
+	allowedHosts, err := nrpe.ParseAllowedHosts(*nrpeAllowedHosts)
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if *nrpeTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(*nrpeTLSCertFile, *nrpeTLSKeyFile)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+
+	srv := nrpe.NewServer(nrpeHandlerFunc(logger, logLevel), logger, tlsConfig, allowedHosts)
+
+	go func() {
+		level.Info(logger).Log("msg", "Listening for NRPE queries", "address", *nrpeListenAddress, "tls", tlsConfig != nil)
+		if err := srv.ListenAndServe(*nrpeListenAddress); err != nil {
+			level.Error(logger).Log("msg", "Error starting NRPE server", "err", err)
+			srvc <- struct{}{}
+		}
+	}()
+
+	return nil
+}
+
 func stopServer(srvc chan struct{}, logger log.Logger) int {
 	term := make(chan os.Signal, 1)
 	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
@@ -231,27 +500,74 @@ func stopServer(srvc chan struct{}, logger log.Logger) int {
 	}
 }
 
+// checkConfig renders every module of the loaded configuration
+// and logs all problems found along the way
+func checkConfig(logger log.Logger) int {
+	var errs []error
+	sc.ProvideConfig(func(c *config.Config) {
+		errs = nagios.NewPluginBuilder(tc).CheckConfig(c)
+	})
+
+	for _, err := range errs {
+		var cerr *nagios.CheckError
+		if errors.As(err, &cerr) {
+			level.Error(logger).Log("msg", "Invalid module", "module", cerr.Module, "argument", cerr.Argument, "err", cerr.Err)
+		} else {
+			level.Error(logger).Log("msg", "Invalid module", "err", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		level.Error(logger).Log("msg", "Config file is invalid", "errors", len(errs))
+		return 1
+	}
+
+	level.Info(logger).Log("msg", "Config file is ok exiting...")
+	return 0
+}
+
 func run() int {
-	logger := parseArgs()
+	logger, command := parseArgs()
+
+	switch command {
+	case importIcingaCommand.FullCommand():
+		return runImportIcinga(logger)
+	case importNagiosCommand.FullCommand():
+		return runImportNagios(logger)
+	case schemaCommand.FullCommand():
+		return runSchema(logger)
+	case lintCommand.FullCommand():
+		return runLint(logger)
+	}
+
 	logLevelProberValue, _ := level.Parse(*logLevelProber)
 	logLevelProber := level.Allow(logLevelProberValue)
 
 	level.Info(logger).Log("msg", "Starting "+name, "version", version.Info())
 	level.Info(logger).Log("build_context", version.BuildContext())
 
-	if err := sc.ReloadConfig(*configFile, logger); err != nil {
+	sc.SetStrict(*configStrict)
+	sc.SetModuleChecker(nagios.NewPluginBuilder(tc).Check)
+	sc.SetHistorySize(*configHistory)
+	if *configOverlayFile != "" {
+		if err := sc.SetOverlayFile(*configOverlayFile); err != nil {
+			level.Error(logger).Log("msg", "Error loading overlay file", "err", err)
+			return 1
+		}
+	}
+
+	if _, err := sc.ReloadConfig(*configFile, logger); err != nil {
 		level.Error(logger).Log("msg", "Error loading config", "err", err)
 		return 1
 	}
 
 	if *configCheck {
-		level.Info(logger).Log("msg", "Config file is ok exiting...")
-		return 0
+		return checkConfig(logger)
 	}
 
 	level.Info(logger).Log("msg", "Loaded config file")
 
-	reloadCh := make(chan chan error)
+	reloadCh := make(chan chan reloadResult)
 	watchConfig(reloadCh, logger)
 
 	if landingPage, err := rootHandler(); err != nil {
@@ -262,13 +578,41 @@ func run() int {
 	}
 
 	http.Handle(telemetryEndpoint, promhttp.Handler())
-	http.HandleFunc(reloadEndpoint, reloadHandlerFunc(reloadCh))
+	http.HandleFunc(reloadEndpoint, reloadHandlerFunc(reloadCh, logger))
 	http.HandleFunc(configEndpoint, configHandlerFunc(logger))
+	http.HandleFunc(schemaEndpoint, schemaHandlerFunc(logger))
+	http.HandleFunc(historyEndpoint, historyHandlerFunc(logger))
+	http.HandleFunc(rollbackEndpoint, rollbackHandlerFunc(logger))
+	http.HandleFunc(modulesEndpoint, modulesHandlerFunc(*webModuleAPI, logger))
+	http.HandleFunc(modulesEndpoint+"/", modulesHandlerFunc(*webModuleAPI, logger))
 	http.HandleFunc(healthEndpoint, healthHandlerFunc())
 	http.HandleFunc(probeEndpoint, probeHandlerFunc(logger, logLevelProber))
 
-	srvc := make(chan struct{})
+	ps := passive.NewStore(*passiveFreshness, *passiveRetention, *passiveMaxObjects)
+	prometheus.MustRegister(passive.NewCollector(ident, ps))
+
+	if *webPassive {
+		http.Handle(passiveEndpoint, passive.NewIcingaHandler(ps, logger))
+	}
+
+	// every server signals its failure once; the buffer
+	// ensures none of them blocks after the first failure
+	srvc := make(chan struct{}, 3)
 	runServer(srvc, logger)
 
+	if *nscaListenAddress != "" {
+		if err := runNSCAServer(srvc, ps, logger); err != nil {
+			level.Error(logger).Log("msg", "Unable to set up NSCA server", "err", err)
+			return 1
+		}
+	}
+
+	if *nrpeListenAddress != "" {
+		if err := runNRPEServer(srvc, logger, logLevelProber); err != nil {
+			level.Error(logger).Log("msg", "Unable to set up NRPE server", "err", err)
+			return 1
+		}
+	}
+
 	return stopServer(srvc, logger)
 }
@@ -0,0 +1,118 @@
+package nagios
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Capability is a Linux capability as defined in linux/capability.h
+type Capability uint
+
+const (
+	CapNetBindService Capability = 10
+	CapNetRaw         Capability = 13
+)
+
+// capability data revisions of the security.capability extended attribute
+const (
+	vfsCapRevisionMask   = 0xFF000000
+	vfsCapFlagsEffective = 0x000001
+	vfsCapRevision1      = 0x01000000
+	vfsCapRevision2      = 0x02000000
+	vfsCapRevision3      = 0x03000000
+)
+
+var errCapabilitiesUnsupported = errors.New("file capabilities are not supported on this platform")
+
+var capabilityNames = map[Capability]string{
+	CapNetBindService: "cap_net_bind_service",
+	CapNetRaw:         "cap_net_raw",
+}
+
+// PluginCapabilities are the capabilities required by well-known plugins,
+// which need to open raw sockets or bind to privileged ports
+var PluginCapabilities = map[string][]Capability{
+	"check_dhcp": []Capability{CapNetBindService, CapNetRaw},
+	"check_icmp": []Capability{CapNetRaw},
+	"check_ping": []Capability{CapNetRaw},
+}
+
+func (c Capability) String() string {
+	if name, ok := capabilityNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("cap_%d", uint(c))
+}
+
+// CapabilitySet is a bitmask of capabilities
+type CapabilitySet uint64
+
+// Has reports whether the given capability is part of the set
+func (s CapabilitySet) Has(c Capability) bool {
+	return s&(1<<c) != 0
+}
+
+// Missing returns the given capabilities which are not part of the set
+func (s CapabilitySet) Missing(caps ...Capability) []Capability {
+	var result []Capability
+	for _, c := range caps {
+		if !s.Has(c) {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// joinCapabilities renders the given capabilities as comma separated list
+func joinCapabilities(caps []Capability) string {
+	names := make([]string, len(caps))
+	for i, c := range caps {
+		names[i] = c.String()
+	}
+
+	return strings.Join(names, ",")
+}
+
+// ParseFileCapabilities decodes the content of the security.capability
+// extended attribute (struct vfs_cap_data). The result contains the
+// permitted capabilities, which are granted upon execution. It is empty
+// unless the effective flag is set, as plugins do not raise capabilities
+// on their own.
+func ParseFileCapabilities(data []byte) (CapabilitySet, error) {
+	if len(data) < 4 {
+		return 0, fmt.Errorf("capability data too short (%d bytes)", len(data))
+	}
+
+	magic := binary.LittleEndian.Uint32(data)
+
+	var words int
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		words = 1
+	case vfsCapRevision2, vfsCapRevision3:
+		words = 2
+	default:
+		return 0, fmt.Errorf("unsupported capability revision 0x%08x", magic&vfsCapRevisionMask)
+	}
+
+	if len(data) < 4+words*8 {
+		return 0, fmt.Errorf("capability data too short (%d bytes)", len(data))
+	}
+
+	if magic&vfsCapFlagsEffective == 0 {
+		return 0, nil
+	}
+
+	var result CapabilitySet
+	for i := 0; i < words; i++ {
+		// each word consists of the permitted and inheritable set
+		permitted := binary.LittleEndian.Uint32(data[4+i*8:])
+		result |= CapabilitySet(permitted) << (32 * i)
+	}
+
+	return result, nil
+}
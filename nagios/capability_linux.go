@@ -0,0 +1,35 @@
+//go:build linux
+
+package nagios
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const capabilityAttribute = "security.capability"
+
+// fileCapabilities reads the file capabilities of the given path.
+// Files without capabilities yield an empty set.
+func fileCapabilities(path string) (CapabilitySet, error) {
+	data := make([]byte, 64)
+	n, err := unix.Getxattr(path, capabilityAttribute, data)
+	if errors.Is(err, unix.ENODATA) || errors.Is(err, unix.ENOTSUP) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return ParseFileCapabilities(data[:n])
+}
+
+// isSetuidRoot reports whether the file is owned by root and has the
+// setuid bit set, granting all capabilities upon execution
+func isSetuidRoot(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+
+	return ok && stat.Uid == 0 && info.Mode()&os.ModeSetuid != 0
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package nagios
+
+import (
+	"os"
+)
+
+func fileCapabilities(path string) (CapabilitySet, error) {
+	return 0, errCapabilitiesUnsupported
+}
+
+func isSetuidRoot(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,75 @@
+package nagios
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseFileCapabilities(t *testing.T) {
+	type testCase struct {
+		have      []byte
+		want      []Capability
+		wantNone  []Capability
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"revision 2 cap_net_raw+ep": testCase{
+			have:     []byte{0x01, 0x00, 0x00, 0x02, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want:     []Capability{CapNetRaw},
+			wantNone: []Capability{CapNetBindService},
+		},
+		"revision 2 cap_net_bind_service,cap_net_raw+ep": testCase{
+			have: []byte{0x01, 0x00, 0x00, 0x02, 0x00, 0x24, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: []Capability{CapNetBindService, CapNetRaw},
+		},
+		"revision 2 cap_net_raw+p": testCase{
+			have:     []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantNone: []Capability{CapNetRaw},
+		},
+		"revision 3 cap_net_raw+ep": testCase{
+			have: []byte{0x01, 0x00, 0x00, 0x03, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe8, 0x03, 0x00, 0x00},
+			want: []Capability{CapNetRaw},
+		},
+		"revision 1 cap_net_raw+ep": testCase{
+			have: []byte{0x01, 0x00, 0x00, 0x01, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: []Capability{CapNetRaw},
+		},
+		"truncated": testCase{
+			have:      []byte{0x01, 0x00, 0x00, 0x02, 0x00, 0x20},
+			wantError: true,
+		},
+		"unknown revision": testCase{
+			have:      []byte{0x01, 0x00, 0x00, 0x09, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, err := ParseFileCapabilities(tc.have)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			for _, c := range tc.want {
+				assert.Assert(t, got.Has(c), "missing %s", c)
+			}
+			for _, c := range tc.wantNone {
+				assert.Assert(t, !got.Has(c), "unexpected %s", c)
+			}
+		})
+	}
+}
+
+func TestCapabilitySetMissing(t *testing.T) {
+	have := CapabilitySet(1 << CapNetRaw)
+	got := have.Missing(CapNetBindService, CapNetRaw)
+
+	assert.DeepEqual(t, []Capability{CapNetBindService}, got)
+	assert.Equal(t, "cap_net_bind_service", joinCapabilities(got))
+}
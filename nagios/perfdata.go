@@ -206,6 +206,16 @@ func (d *PerfData) Value() string {
 	return d.value.String()
 }
 
+// Unit returns the unit of measurement of the peformance data value,
+// or an empty string if no such information is available
+func (d *PerfData) Unit() string {
+	if d.value == nil {
+		return ""
+	}
+
+	return d.value.Unit
+}
+
 // Float parses the Value() for numeric data or returns 0 otherwise.
 func (d *PerfData) Float() float64 {
 	if d.value == nil {
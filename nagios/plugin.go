@@ -8,6 +8,14 @@ import (
 	"strings"
 )
 
+// Runner is implemented by any check execution definition
+type Runner interface {
+	// Run executes the check and reports its result
+	Run(ctx context.Context) (*PluginResult, error)
+	// String creates a human readable representation of the check
+	String() string
+}
+
 // Plugin represents a Nagios plugin execution definition
 type Plugin struct {
 	command     string
@@ -37,9 +45,20 @@ func NewPlugin(command string, arguments, environment []string) *Plugin {
 // String creates a rudimentary commandline representation,
 // using the command and its arguments
 func (p *Plugin) String() string {
-	s := make([]string, 0, len(p.arguments)+1)
+	return p.FormatArguments(nil)
+}
+
+// FormatArguments creates the commandline representation
+// using the arguments returned by the given function
+func (p *Plugin) FormatArguments(fn func([]string) []string) string {
+	args := p.arguments
+	if fn != nil {
+		args = fn(args)
+	}
+
+	s := make([]string, 0, len(args)+1)
 	s = append(s, p.command)
-	s = append(s, p.arguments...)
+	s = append(s, args...)
 
 	return strings.Join(s, " ")
 }
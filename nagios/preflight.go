@@ -0,0 +1,58 @@
+package nagios
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Preflight verifies that the given plugin command can be executed safely.
+// Commands without path separators are looked up in PATH. The command must
+// be a regular executable file, which is not world-writable. Well-known
+// plugins listed in PluginCapabilities must be granted the required
+// capabilities, unless the exporter runs as root or the plugin is
+// setuid root.
+func Preflight(command string) error {
+	path := command
+	if filepath.Base(command) == command {
+		p, err := exec.LookPath(command)
+		if err != nil {
+			return err
+		}
+
+		path = p
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	} else if info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	} else if info.Mode().Perm()&0002 != 0 {
+		return fmt.Errorf("%s is world-writable", path)
+	}
+
+	caps := PluginCapabilities[filepath.Base(path)]
+	if len(caps) == 0 || os.Geteuid() == 0 || isSetuidRoot(info) {
+		return nil
+	}
+
+	granted, err := fileCapabilities(path)
+	if errors.Is(err, errCapabilitiesUnsupported) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read capabilities of %s: %w", path, err)
+	}
+
+	if missing := granted.Missing(caps...); len(missing) > 0 {
+		return fmt.Errorf("%s is missing the capabilities %s", path, joinCapabilities(missing))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package nagios
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+
+	type testCase struct {
+		mode      os.FileMode
+		directory bool
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"executable": testCase{
+			mode: 0755,
+		},
+		"not executable": testCase{
+			mode:      0644,
+			wantError: true,
+		},
+		"world-writable": testCase{
+			mode:      0777,
+			wantError: true,
+		},
+		"directory": testCase{
+			mode:      0755,
+			directory: true,
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			path := filepath.Join(dir, ctx)
+			if tc.directory {
+				assert.NilError(t, os.Mkdir(path, tc.mode))
+			} else {
+				assert.NilError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), tc.mode))
+			}
+			// bypass the umask
+			assert.NilError(t, os.Chmod(path, tc.mode))
+
+			err := Preflight(path)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		assert.Assert(t, Preflight(filepath.Join(dir, "missing")) != nil)
+		assert.Assert(t, Preflight("check_missing_from_path") != nil)
+	})
+}
@@ -0,0 +1,133 @@
+package nagios
+
+import (
+	"sort"
+	"strings"
+)
+
+// minSubstringLength is the minimum length of secrets which are
+// masked wherever they occur. Shorter secrets are too likely to match
+// unrelated text (e.g. numbers in the plugin output) and are only
+// masked as whole command line arguments.
+const minSubstringLength = 4
+
+// ArgumentFormatter is implemented by runners whose human readable
+// representation is made up of command line arguments
+type ArgumentFormatter interface {
+	// FormatArguments creates the human readable representation
+	// using the arguments returned by the given function
+	FormatArguments(fn func([]string) []string) string
+}
+
+// Redactor masks secret values in human readable representations
+type Redactor struct {
+	secrets  map[string]bool
+	mask     string
+	replacer *strings.Replacer
+}
+
+// NewRedactor creates a redactor masking command line arguments
+// which equal any of the secrets, as well as the value of key=value
+// arguments. Secrets of at least four characters are masked wherever
+// they occur. The result is nil if there are no secrets, which is a
+// valid redactor leaving everything as is.
+func NewRedactor(secrets []string, mask string) *Redactor {
+	values := make([]string, 0, len(secrets))
+	lookup := make(map[string]bool, len(secrets))
+	for _, s := range secrets {
+		if s != "" && !lookup[s] {
+			values = append(values, s)
+			lookup[s] = true
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	// mask the longest secrets first, in case they contain shorter ones
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, s := range values {
+		if len(s) >= minSubstringLength {
+			pairs = append(pairs, s, mask)
+		}
+	}
+
+	result := &Redactor{
+		secrets: lookup,
+		mask:    mask,
+	}
+
+	if len(pairs) > 0 {
+		result.replacer = strings.NewReplacer(pairs...)
+	}
+
+	return result
+}
+
+// Replace masks the secrets in the given string
+func (r *Redactor) Replace(s string) string {
+	if r == nil || r.replacer == nil {
+		return s
+	}
+
+	return r.replacer.Replace(s)
+}
+
+// Arguments creates a copy of the given command line arguments
+// with the secrets masked
+func (r *Redactor) Arguments(args []string) []string {
+	if r == nil {
+		return args
+	}
+
+	result := make([]string, len(args))
+	for i, arg := range args {
+		if r.secrets[arg] {
+			result[i] = r.mask
+		} else if k, v, ok := strings.Cut(arg, "="); ok && r.secrets[v] {
+			result[i] = k + "=" + r.mask
+		} else {
+			result[i] = r.Replace(arg)
+		}
+	}
+
+	return result
+}
+
+// Runner wraps the given runner, masking the secrets in its
+// string representation. The runner is returned as is if
+// there are no secrets.
+func (r *Redactor) Runner(runner Runner) Runner {
+	if r == nil {
+		return runner
+	}
+
+	result := &redactedRunner{
+		Runner:   runner,
+		redactor: r,
+	}
+
+	return result
+}
+
+// redactedRunner masks secret values in the
+// representation of the wrapped runner
+type redactedRunner struct {
+	Runner
+	redactor *Redactor
+}
+
+// String creates a human readable representation of
+// the wrapped runner without any of the secrets
+func (r *redactedRunner) String() string {
+	if f, ok := r.Runner.(ArgumentFormatter); ok {
+		return f.FormatArguments(r.redactor.Arguments)
+	}
+
+	return r.redactor.Replace(r.Runner.String())
+}
@@ -0,0 +1,82 @@
+package nagios
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRedactorRunner(t *testing.T) {
+	type testCase struct {
+		secrets []string
+		want    string
+	}
+
+	testCases := map[string]testCase{
+		"none": testCase{
+			want: "/bin/check_snmp -H 10.0.0.1 -C public -P 2c --auth=md5:s3cr3t",
+		},
+		"empty secret": testCase{
+			secrets: []string{""},
+			want:    "/bin/check_snmp -H 10.0.0.1 -C public -P 2c --auth=md5:s3cr3t",
+		},
+		"secret": testCase{
+			secrets: []string{"public"},
+			want:    "/bin/check_snmp -H 10.0.0.1 -C <secret> -P 2c --auth=md5:s3cr3t",
+		},
+		"overlapping secrets": testCase{
+			secrets: []string{"pub", "public"},
+			want:    "/bin/check_snmp -H 10.0.0.1 -C <secret> -P 2c --auth=md5:s3cr3t",
+		},
+		"key value": testCase{
+			secrets: []string{"md5:s3cr3t"},
+			want:    "/bin/check_snmp -H 10.0.0.1 -C public -P 2c --auth=<secret>",
+		},
+		"substring": testCase{
+			secrets: []string{"s3cr3t"},
+			want:    "/bin/check_snmp -H 10.0.0.1 -C public -P 2c --auth=md5:<secret>",
+		},
+		"short secret": testCase{
+			secrets: []string{"0", "2c"},
+			want:    "/bin/check_snmp -H 10.0.0.1 -C public -P <secret> --auth=md5:s3cr3t",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			plugin := NewArgumentPlugin("/bin/check_snmp", "-H", "10.0.0.1", "-C", "public", "-P", "2c", "--auth=md5:s3cr3t")
+			got := NewRedactor(tc.secrets, "<secret>").Runner(plugin)
+
+			assert.Equal(t, tc.want, got.String())
+		})
+	}
+}
+
+func TestRedactorReplace(t *testing.T) {
+	type testCase struct {
+		secrets []string
+		want    string
+	}
+
+	testCases := map[string]testCase{
+		"none": testCase{
+			want: "SNMP OK - public 10 | time=0.01s",
+		},
+		"secret": testCase{
+			secrets: []string{"public"},
+			want:    "SNMP OK - <secret> 10 | time=0.01s",
+		},
+		"short secret": testCase{
+			secrets: []string{"0", "public"},
+			want:    "SNMP OK - <secret> 10 | time=0.01s",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got := NewRedactor(tc.secrets, "<secret>").Replace("SNMP OK - public 10 | time=0.01s")
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
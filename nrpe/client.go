@@ -0,0 +1,145 @@
+package nrpe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// Client represents a check execution on a remote NRPE daemon
+type Client struct {
+	address   string
+	command   string
+	arguments []string
+	version   int16
+	tlsConfig *tls.Config
+}
+
+// NewClient creates a new client instance querying the daemon at the given
+// address for the execution of the command with the given arguments.
+// The connection is unencrypted if tlsConfig is nil.
+func NewClient(address, command string, arguments []string, version int16, tlsConfig *tls.Config) *Client {
+	result := &Client{
+		address:   address,
+		command:   command,
+		arguments: arguments,
+		version:   version,
+		tlsConfig: tlsConfig,
+	}
+
+	return result
+}
+
+// Query returns the command and arguments in the NRPE query notation
+func (c *Client) Query() (string, error) {
+	if c.command == "" || strings.Contains(c.command, ArgumentDelimiter) {
+		return "", fmt.Errorf("Invalid NRPE command %q", c.command)
+	}
+
+	for _, a := range c.arguments {
+		if strings.Contains(a, ArgumentDelimiter) {
+			return "", fmt.Errorf("NRPE argument %q must not contain %q", a, ArgumentDelimiter)
+		}
+	}
+
+	s := make([]string, 0, len(c.arguments)+1)
+	s = append(s, c.command)
+	s = append(s, c.arguments...)
+
+	return strings.Join(s, ArgumentDelimiter), nil
+}
+
+// String creates a rudimentary URL representation,
+// using the address, command, and its arguments
+func (c *Client) String() string {
+	return c.FormatArguments(nil)
+}
+
+// FormatArguments creates the URL representation
+// using the arguments returned by the given function
+func (c *Client) FormatArguments(fn func([]string) []string) string {
+	args := c.arguments
+	if fn != nil {
+		args = fn(args)
+	}
+
+	s := make([]string, 0, len(args)+1)
+	s = append(s, c.command)
+	s = append(s, args...)
+
+	return "nrpe://" + c.address + "/" + strings.Join(s, ArgumentDelimiter)
+}
+
+// Run sends the query to the NRPE daemon and decodes its response.
+// Any error is the result of the daemon not being reachable or
+// responding with an invalid packet.
+func (c *Client) Run(ctx context.Context) (*nagios.PluginResult, error) {
+	query, err := c.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := &Packet{
+		Version: c.version,
+		Type:    QueryPacket,
+		Buffer:  query,
+	}
+	if err := WritePacket(conn, request); err != nil {
+		return nil, err
+	}
+
+	response, err := ReadPacket(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type != ResponsePacket {
+		return nil, fmt.Errorf("Unexpected NRPE packet type %d", response.Type)
+	}
+
+	result := &nagios.PluginResult{}
+	if err := nagios.NewPluginResultDecoder(strings.NewReader(response.Buffer)).Decode(result); err != nil {
+		return nil, err
+	}
+
+	result.Status = nagios.ExitCode(response.ResultCode)
+
+	return result, nil
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		d := &net.Dialer{}
+		return d.DialContext(ctx, "tcp", c.address)
+	}
+
+	d := &tls.Dialer{
+		Config: c.tlsConfig,
+	}
+
+	return d.DialContext(ctx, "tcp", c.address)
+}
+
+// JoinHostPort appends the default NRPE port to the
+// given address, unless it already contains one
+func JoinHostPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+
+	return net.JoinHostPort(strings.Trim(address, "[]"), DefaultPort)
+}
@@ -0,0 +1,138 @@
+package nrpe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// serveNRPE mimics a NRPE daemon answering a single query
+func serveNRPE(t *testing.T, l net.Listener, resultCode int16, output string) <-chan *Packet {
+	queries := make(chan *Packet, 1)
+
+	go func() {
+		defer close(queries)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		query, err := ReadPacket(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		queries <- query
+
+		response := &Packet{
+			Version:    query.Version,
+			Type:       ResponsePacket,
+			ResultCode: resultCode,
+			Buffer:     output,
+		}
+		if err := WritePacket(conn, response); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	return queries
+}
+
+func TestClientQuery(t *testing.T) {
+	type testCase struct {
+		command   string
+		arguments []string
+		want      string
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"command only": testCase{
+			command: "check_load",
+			want:    "check_load",
+		},
+		"arguments": testCase{
+			command:   "check_disk",
+			arguments: []string{"-w", "20%", "/var"},
+			want:      "check_disk!-w!20%!/var",
+		},
+		"empty command": testCase{
+			wantError: true,
+		},
+		"delimiter in argument": testCase{
+			command:   "check_disk",
+			arguments: []string{"a!b"},
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			subject := NewClient("localhost:5666", tc.command, tc.arguments, PacketVersion3, nil)
+			got, err := subject.Query()
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClientRun(t *testing.T) {
+	for _, version := range []int16{PacketVersion2, PacketVersion3} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NilError(t, err)
+		defer l.Close()
+
+		queries := serveNRPE(t, l, 1, "LOAD WARNING - load average: 5.00|load1=5.000;4;8;0")
+		subject := NewClient(l.Addr().String(), "check_load", []string{"4", "8"}, version, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		got, err := subject.Run(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, nagios.WARNING, got.Status)
+		assert.Equal(t, "LOAD WARNING - load average: 5.00", got.Output)
+		assert.Equal(t, 1, len(got.PerfData))
+
+		query := <-queries
+		assert.Equal(t, version, query.Version)
+		assert.Equal(t, QueryPacket, query.Type)
+		assert.Equal(t, "check_load!4!8", query.Buffer)
+	}
+}
+
+func TestJoinHostPort(t *testing.T) {
+	type testCase struct {
+		have string
+		want string
+	}
+
+	testCases := map[string]testCase{
+		"hostname":           testCase{have: "localhost", want: "localhost:5666"},
+		"hostname with port": testCase{have: "localhost:1234", want: "localhost:1234"},
+		"IPv6":               testCase{have: "::1", want: "[::1]:5666"},
+		"bracketed IPv6":     testCase{have: "[::1]", want: "[::1]:5666"},
+		"IPv6 with port":     testCase{have: "[::1]:1234", want: "[::1]:1234"},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got := JoinHostPort(tc.have)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
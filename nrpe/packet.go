@@ -0,0 +1,166 @@
+package nrpe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// DefaultPort is the default TCP port of the NRPE daemon
+	DefaultPort = "5666"
+
+	// ArgumentDelimiter separates the command name and its
+	// arguments in a query packet
+	ArgumentDelimiter = "!"
+
+	PacketVersion2 int16 = 2
+	PacketVersion3 int16 = 3
+
+	QueryPacket    int16 = 1
+	ResponsePacket int16 = 2
+
+	// size of the fields shared by all packet versions
+	// (version, type, crc32, result code, alignment)
+	commonSize = 2 + 2 + 4 + 2 + 2
+	// v2 packets have a fixed buffer; the struct is padded
+	// to a multiple of 4 bytes
+	v2BufferSize = 1024
+	v2PacketSize = 2 + 2 + 4 + 2 + v2BufferSize + 2
+	// v3 packets prepend the buffer with its length
+	v3HeaderSize = commonSize + 4
+	// upper limit for v3 buffers to guard against hostile peers
+	v3MaxBufferSize = 64 * 1024
+)
+
+var errChecksum = errors.New("NRPE packet checksum mismatch")
+
+// Packet is a NRPE query or response
+type Packet struct {
+	Version    int16
+	Type       int16
+	ResultCode int16
+	Buffer     string
+}
+
+// MarshalBinary encodes the packet according to its version.
+// The checksum is calculated as well.
+func (p *Packet) MarshalBinary() ([]byte, error) {
+	var buf []byte
+	var offset int
+
+	switch p.Version {
+	case PacketVersion2:
+		if len(p.Buffer) >= v2BufferSize {
+			return nil, fmt.Errorf("NRPE v2 packet buffer exceeds %d bytes", v2BufferSize-1)
+		}
+
+		buf = make([]byte, v2PacketSize)
+		offset = 10
+	case PacketVersion3:
+		// the buffer is NUL terminated; check_nrpe never
+		// sends less than the size of a v2 buffer
+		size := len(p.Buffer) + 1
+		if size < v2BufferSize {
+			size = v2BufferSize
+		} else if size > v3MaxBufferSize {
+			return nil, fmt.Errorf("NRPE v3 packet buffer exceeds %d bytes", v3MaxBufferSize-1)
+		}
+
+		buf = make([]byte, v3HeaderSize+size)
+		binary.BigEndian.PutUint32(buf[commonSize:], uint32(size))
+		offset = v3HeaderSize
+	default:
+		return nil, fmt.Errorf("Unsupported NRPE packet version %d", p.Version)
+	}
+
+	binary.BigEndian.PutUint16(buf[0:], uint16(p.Version))
+	binary.BigEndian.PutUint16(buf[2:], uint16(p.Type))
+	binary.BigEndian.PutUint16(buf[8:], uint16(p.ResultCode))
+	copy(buf[offset:], p.Buffer)
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(buf))
+
+	return buf, nil
+}
+
+// WritePacket encodes the given packet and writes it to w
+func WritePacket(w io.Writer, p *Packet) error {
+	buf, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// ReadPacket reads and verifies a single packet of any
+// supported version from r
+func ReadPacket(r io.Reader) (*Packet, error) {
+	var buf []byte
+	var offset int
+
+	common := make([]byte, commonSize)
+	if _, err := io.ReadFull(r, common); err != nil {
+		return nil, err
+	}
+
+	version := int16(binary.BigEndian.Uint16(common[0:]))
+	switch version {
+	case PacketVersion2:
+		buf = make([]byte, v2PacketSize)
+		copy(buf, common)
+		if _, err := io.ReadFull(r, buf[commonSize:]); err != nil {
+			return nil, err
+		}
+
+		offset = 10
+	case PacketVersion3:
+		length := make([]byte, 4)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return nil, err
+		}
+
+		size := binary.BigEndian.Uint32(length)
+		if size > v3MaxBufferSize {
+			return nil, fmt.Errorf("NRPE v3 packet buffer of %d bytes exceeds the limit", size)
+		}
+
+		buf = make([]byte, v3HeaderSize+int(size))
+		copy(buf, common)
+		copy(buf[commonSize:], length)
+		if _, err := io.ReadFull(r, buf[v3HeaderSize:]); err != nil {
+			return nil, err
+		}
+
+		offset = v3HeaderSize
+	default:
+		return nil, fmt.Errorf("Unsupported NRPE packet version %d", version)
+	}
+
+	crc := binary.BigEndian.Uint32(buf[4:])
+	binary.BigEndian.PutUint32(buf[4:], 0)
+	if crc32.ChecksumIEEE(buf) != crc {
+		return nil, errChecksum
+	}
+
+	result := &Packet{
+		Version:    version,
+		Type:       int16(binary.BigEndian.Uint16(buf[2:])),
+		ResultCode: int16(binary.BigEndian.Uint16(buf[8:])),
+		Buffer:     nulString(buf[offset:]),
+	}
+
+	return result, nil
+}
+
+func nulString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,77 @@
+package nrpe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPacketRoundtrip(t *testing.T) {
+	type testCase struct {
+		have     *Packet
+		wantSize int
+	}
+
+	testCases := map[string]testCase{
+		"v2 query": testCase{
+			have:     &Packet{Version: PacketVersion2, Type: QueryPacket, Buffer: "check_load!1!2"},
+			wantSize: 1036,
+		},
+		"v2 response": testCase{
+			have:     &Packet{Version: PacketVersion2, Type: ResponsePacket, ResultCode: 2, Buffer: "LOAD CRITICAL|load1=5"},
+			wantSize: 1036,
+		},
+		"v3 query": testCase{
+			have:     &Packet{Version: PacketVersion3, Type: QueryPacket, Buffer: "check_load"},
+			wantSize: 16 + 1024,
+		},
+		"v3 large response": testCase{
+			have:     &Packet{Version: PacketVersion3, Type: ResponsePacket, ResultCode: 1, Buffer: strings.Repeat("x", 2000)},
+			wantSize: 16 + 2001,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			buf, err := tc.have.MarshalBinary()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantSize, len(buf))
+
+			got, err := ReadPacket(bytes.NewReader(buf))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.have, got)
+
+			buf[len(buf)-1] ^= 0xff
+			_, err = ReadPacket(bytes.NewReader(buf))
+			assert.ErrorIs(t, err, errChecksum)
+		})
+	}
+}
+
+func TestPacketMarshalBinaryError(t *testing.T) {
+	type testCase struct {
+		have *Packet
+	}
+
+	testCases := map[string]testCase{
+		"unknown version": testCase{
+			have: &Packet{Version: 4},
+		},
+		"v2 buffer overflow": testCase{
+			have: &Packet{Version: PacketVersion2, Buffer: strings.Repeat("x", 1024)},
+		},
+		"v3 buffer overflow": testCase{
+			have: &Packet{Version: PacketVersion3, Buffer: strings.Repeat("x", 64*1024)},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			_, err := tc.have.MarshalBinary()
+
+			assert.Assert(t, err != nil)
+		})
+	}
+}
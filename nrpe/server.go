@@ -0,0 +1,217 @@
+package nrpe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// CheckCommand is the query sent by check_nrpe if no command
+// has been specified; it is used to test the connectivity
+const CheckCommand = "_NRPE_CHECK"
+
+// Handler executes a check on behalf of a NRPE query
+type Handler interface {
+	ServeNRPE(ctx context.Context, command string, arguments []string) (*nagios.PluginResult, error)
+}
+
+// HandlerFunc is an adapter to allow the use of
+// ordinary functions as NRPE handlers
+type HandlerFunc func(ctx context.Context, command string, arguments []string) (*nagios.PluginResult, error)
+
+// ServeNRPE calls f(ctx, command, arguments)
+func (f HandlerFunc) ServeNRPE(ctx context.Context, command string, arguments []string) (*nagios.PluginResult, error) {
+	return f(ctx, command, arguments)
+}
+
+// Server answers NRPE queries using the provided handler
+type Server struct {
+	handler      Handler
+	logger       log.Logger
+	tlsConfig    *tls.Config
+	allowedHosts []*net.IPNet
+	timeout      time.Duration
+}
+
+// NewServer creates a new server instance. Connections are accepted
+// unencrypted if tlsConfig is nil. If allowedHosts is empty, connections
+// from any address are accepted.
+func NewServer(handler Handler, logger log.Logger, tlsConfig *tls.Config, allowedHosts []*net.IPNet) *Server {
+	result := &Server{
+		handler:      handler,
+		logger:       logger,
+		tlsConfig:    tlsConfig,
+		allowedHosts: allowedHosts,
+		timeout:      10 * time.Second,
+	}
+
+	return result
+}
+
+// ParseAllowedHosts parses a comma separated list of addresses
+// and networks in CIDR notation
+func ParseAllowedHosts(s string) ([]*net.IPNet, error) {
+	result := []*net.IPNet{}
+
+	for _, h := range strings.Split(s, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		if !strings.Contains(h, "/") {
+			ip := net.ParseIP(h)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid allowed host %q", h)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(h)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, n)
+	}
+
+	return result, nil
+}
+
+// ListenAndServe listens on the given TCP address and serves
+// incoming connections
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the given listener until it is closed
+func (s *Server) Serve(l net.Listener) error {
+	if s.tlsConfig != nil {
+		l = tls.NewListener(l, s.tlsConfig)
+	}
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) allowed(addr net.Addr) bool {
+	if len(s.allowedHosts) == 0 {
+		return true
+	}
+
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, n := range s.allowedHosts {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	logger := log.With(s.logger, "remote", conn.RemoteAddr())
+
+	if !s.allowed(conn.RemoteAddr()) {
+		level.Warn(logger).Log("msg", "Rejecting NRPE connection from disallowed host")
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(s.timeout))
+	query, err := ReadPacket(conn)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Rejecting NRPE query", "err", err)
+		return
+	}
+
+	if query.Type != QueryPacket {
+		level.Warn(logger).Log("msg", "Rejecting NRPE query", "err", fmt.Sprintf("unexpected packet type %d", query.Type))
+		return
+	}
+
+	command, arguments, _ := strings.Cut(query.Buffer, ArgumentDelimiter)
+	var argv []string
+	if arguments != "" {
+		argv = strings.Split(arguments, ArgumentDelimiter)
+	}
+
+	response := &Packet{
+		Version: query.Version,
+		Type:    ResponsePacket,
+	}
+
+	if command == CheckCommand {
+		response.Buffer = "NRPE v" + fmt.Sprint(query.Version)
+	} else {
+		level.Debug(logger).Log("msg", "Received NRPE query", "command", command, "arguments", len(argv))
+
+		result, err := s.handler.ServeNRPE(context.Background(), command, argv)
+		if err != nil {
+			// the details are not disclosed to remote clients
+			level.Warn(logger).Log("msg", "NRPE query failed", "command", command, "err", err)
+			response.ResultCode = int16(nagios.UNKNOWN)
+			response.Buffer = fmt.Sprintf("NRPE: Unable to run command '%s'", command)
+		} else {
+			response.ResultCode = int16(result.Status)
+			response.Buffer = responseText(result)
+		}
+	}
+
+	if query.Version == PacketVersion2 && len(response.Buffer) >= v2BufferSize {
+		response.Buffer = response.Buffer[:v2BufferSize-1]
+	} else if len(response.Buffer) >= v3MaxBufferSize {
+		response.Buffer = response.Buffer[:v3MaxBufferSize-1]
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if err := WritePacket(conn, response); err != nil {
+		level.Warn(logger).Log("msg", "Unable to send NRPE response", "err", err)
+	}
+}
+
+// responseText renders the plugin result including its trailer
+func responseText(r *nagios.PluginResult) string {
+	lines := make([]string, 0, len(r.Trailer)+1)
+	lines = append(lines, r.String())
+	lines = append(lines, r.Trailer...)
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,171 @@
+package nrpe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+func handlerMock(ctx context.Context, command string, arguments []string) (*nagios.PluginResult, error) {
+	switch command {
+	case "check_args":
+		return &nagios.PluginResult{
+			Status: nagios.WARNING,
+			Output: "ARGS " + strings.Join(arguments, ","),
+		}, nil
+	case "check_long":
+		return &nagios.PluginResult{
+			Status: nagios.OK,
+			Output: strings.Repeat("x", 2000),
+		}, nil
+	}
+
+	return nil, errors.New("Command '" + command + "' not defined")
+}
+
+func TestParseAllowedHosts(t *testing.T) {
+	type testCase struct {
+		have      string
+		want      []string
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"empty": testCase{
+			have: "",
+			want: []string{},
+		},
+		"addresses": testCase{
+			have: "127.0.0.1, ::1",
+			want: []string{"127.0.0.1/32", "::1/128"},
+		},
+		"networks": testCase{
+			have: "10.0.0.0/8,fd00::/8",
+			want: []string{"10.0.0.0/8", "fd00::/8"},
+		},
+		"hostname": testCase{
+			have:      "localhost",
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, err := ParseAllowedHosts(tc.have)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			nets := make([]string, len(got))
+			for i, n := range got {
+				nets[i] = n.String()
+			}
+			assert.DeepEqual(t, tc.want, nets)
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	type testCase struct {
+		version      int16
+		command      string
+		arguments    []string
+		allowedHosts string
+		wantStatus   nagios.ExitCode
+		wantOutput   string
+		wantError    bool
+	}
+
+	testCases := map[string]testCase{
+		"v3 arguments": testCase{
+			version:    PacketVersion3,
+			command:    "check_args",
+			arguments:  []string{"one", "two"},
+			wantStatus: nagios.WARNING,
+			wantOutput: "ARGS one,two",
+		},
+		"v2 arguments": testCase{
+			version:    PacketVersion2,
+			command:    "check_args",
+			wantStatus: nagios.WARNING,
+			wantOutput: "ARGS",
+		},
+		"v2 truncated output": testCase{
+			version:    PacketVersion2,
+			command:    "check_long",
+			wantStatus: nagios.OK,
+			wantOutput: strings.Repeat("x", 1023-len("OK: ")),
+		},
+		"v3 long output": testCase{
+			version:    PacketVersion3,
+			command:    "check_long",
+			wantStatus: nagios.OK,
+			wantOutput: strings.Repeat("x", 2000),
+		},
+		"unknown command": testCase{
+			version:    PacketVersion3,
+			command:    "check_missing",
+			wantStatus: nagios.UNKNOWN,
+			wantOutput: "NRPE: Unable to run command 'check_missing'",
+		},
+		"connectivity check": testCase{
+			version:    PacketVersion3,
+			command:    CheckCommand,
+			wantStatus: nagios.OK,
+			wantOutput: "NRPE v3",
+		},
+		"local host": testCase{
+			version:      PacketVersion3,
+			command:      CheckCommand,
+			allowedHosts: "127.0.0.1,::1",
+			wantStatus:   nagios.OK,
+			wantOutput:   "NRPE v3",
+		},
+		"disallowed host": testCase{
+			version:      PacketVersion3,
+			command:      "check_args",
+			allowedHosts: "192.0.2.0/24",
+			wantError:    true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			allowedHosts, err := ParseAllowedHosts(tc.allowedHosts)
+			assert.NilError(t, err)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+			defer l.Close()
+
+			subject := NewServer(HandlerFunc(handlerMock), log.NewNopLogger(), nil, allowedHosts)
+			go subject.Serve(l)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			client := NewClient(l.Addr().String(), tc.command, tc.arguments, tc.version, nil)
+			got, err := client.Run(ctx)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantStatus, got.Status)
+			assert.Equal(t, tc.wantOutput, strings.TrimPrefix(got.Output, got.Status.String()+": "))
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package passive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// IcingaSource is the source name of results submitted via the Icinga API
+const IcingaSource = "icinga"
+
+var (
+	errMissingExitStatus = errors.New("Parameter 'exit_status' is required")
+	errInvalidExitStatus = errors.New("Parameter 'exit_status' must be one of 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN)")
+	errMissingObject     = errors.New("No objects found")
+
+	icingaFilterHost    = regexp.MustCompile(`host\.name\s*==\s*"([^"]*)"`)
+	icingaFilterService = regexp.MustCompile(`service\.name\s*==\s*"([^"]*)"`)
+)
+
+// IcingaStrings is a string array, which can be
+// declared using a single-item notation
+type IcingaStrings []string
+
+// UnmarshalJSON populates the instance from the given data
+func (s *IcingaStrings) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = []string{single}
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+
+	*s = multi
+	return nil
+}
+
+// IcingaCheckResult is the request payload of the Icinga 2
+// process-check-result API action
+type IcingaCheckResult struct {
+	Type            string        `json:"type"`
+	Filter          string        `json:"filter"`
+	Host            string        `json:"host"`
+	Service         string        `json:"service"`
+	ExitStatus      *float64      `json:"exit_status"`
+	PluginOutput    string        `json:"plugin_output"`
+	PerformanceData IcingaStrings `json:"performance_data"`
+	CheckSource     string        `json:"check_source"`
+	ExecutionStart  float64       `json:"execution_start"`
+	ExecutionEnd    float64       `json:"execution_end"`
+	TTL             float64       `json:"ttl"`
+}
+
+// Object returns the host and service name the check result
+// belongs to. The service name is empty for host check results.
+func (c *IcingaCheckResult) Object() (host, service string, err error) {
+	if c.Service != "" {
+		host, service, _ = strings.Cut(c.Service, ObjectDelimiter)
+	} else if c.Host != "" {
+		host = c.Host
+	} else if c.Filter != "" {
+		if m := icingaFilterHost.FindStringSubmatch(c.Filter); m != nil {
+			host = m[1]
+		}
+		if m := icingaFilterService.FindStringSubmatch(c.Filter); m != nil {
+			service = m[1]
+		}
+	}
+
+	if host == "" || (strings.EqualFold(c.Type, "Service") && service == "") {
+		return "", "", errMissingObject
+	}
+
+	return host, service, nil
+}
+
+// Result converts the payload into a check result
+func (c *IcingaCheckResult) Result(now time.Time) (*Result, error) {
+	if c.ExitStatus == nil {
+		return nil, errMissingExitStatus
+	} else if !validExitStatus(*c.ExitStatus) {
+		return nil, errInvalidExitStatus
+	}
+
+	host, service, err := c.Object()
+	if err != nil {
+		return nil, err
+	}
+
+	output := &nagios.PluginResult{}
+	if err := nagios.NewPluginResultDecoder(strings.NewReader(c.PluginOutput)).Decode(output); err != nil {
+		return nil, err
+	}
+
+	for _, p := range c.PerformanceData {
+		perfdata, err := nagios.ParsePerfDataOutput(p)
+		if err != nil {
+			return nil, err
+		}
+
+		output.PerfData = append(output.PerfData, perfdata...)
+	}
+
+	output.Status = nagios.ExitCode(*c.ExitStatus)
+
+	result := &Result{
+		Host:     host,
+		Service:  service,
+		Source:   c.CheckSource,
+		Received: now,
+		TTL:      time.Duration(c.TTL * float64(time.Second)),
+		Result:   output,
+	}
+
+	// results from the future would never become stale
+	// and are therefore considered received right now
+	if c.ExecutionEnd > 0 {
+		sec, frac := math.Modf(c.ExecutionEnd)
+		if end := time.Unix(int64(sec), int64(frac*1e9)); end.Before(now) {
+			result.Received = end
+		}
+	}
+
+	if result.Source == "" {
+		result.Source = IcingaSource
+	}
+
+	return result, nil
+}
+
+type icingaStatus struct {
+	Code   float64 `json:"code"`
+	Status string  `json:"status"`
+}
+
+type icingaResponse struct {
+	Results []icingaStatus `json:"results"`
+}
+
+type icingaError struct {
+	Error  float64 `json:"error"`
+	Status string  `json:"status"`
+}
+
+// IcingaHandler accepts check results using the Icinga 2
+// process-check-result API action payload
+type IcingaHandler struct {
+	store  *Store
+	logger log.Logger
+	now    func() time.Time
+}
+
+// NewIcingaHandler creates a new handler, submitting the
+// received check results to the given store
+func NewIcingaHandler(store *Store, logger log.Logger) *IcingaHandler {
+	result := &IcingaHandler{
+		store:  store,
+		logger: logger,
+		now:    time.Now,
+	}
+
+	return result
+}
+
+// ServeHTTP implements http.Handler
+func (h *IcingaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeIcingaError(w, http.StatusMethodNotAllowed, "POST method expected")
+		return
+	}
+
+	payload := &IcingaCheckResult{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		writeIcingaError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %s", err))
+		return
+	}
+
+	// URL parameters take precedence over the request body
+	query := r.URL.Query()
+	if v := query.Get("type"); v != "" {
+		payload.Type = v
+	}
+	if v := query.Get("service"); v != "" {
+		payload.Service = v
+	}
+	if v := query.Get("host"); v != "" {
+		payload.Host = v
+	}
+	if v := query.Get("filter"); v != "" {
+		payload.Filter = v
+	}
+
+	result, err := payload.Result(h.now())
+	if errors.Is(err, errMissingObject) {
+		writeIcingaError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeIcingaError(w, http.StatusBadRequest, err.Error())
+		level.Debug(h.logger).Log("msg", "Rejecting passive check result", "err", err)
+		return
+	}
+
+	if err := h.store.Submit(result); err != nil {
+		writeIcingaError(w, http.StatusServiceUnavailable, err.Error())
+		level.Warn(h.logger).Log("msg", "Rejecting passive check result", "object", result.Name(), "err", err)
+		return
+	}
+	level.Debug(h.logger).Log("msg", "Received passive check result", "object", result.Name(), "source", result.Source, "nagios_result", result.Result.Status)
+
+	response := icingaResponse{
+		Results: []icingaStatus{
+			{
+				Code:   http.StatusOK,
+				Status: fmt.Sprintf("Successfully processed check result for object '%s'.", result.Name()),
+			},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validExitStatus reports whether the given value is a plugin exit code
+func validExitStatus(v float64) bool {
+	return v == math.Trunc(v) && v >= float64(nagios.OK) && v <= float64(nagios.UNKNOWN)
+}
+
+func writeIcingaError(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(icingaError{
+		Error:  float64(code),
+		Status: status,
+	})
+}
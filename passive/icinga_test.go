@@ -0,0 +1,200 @@
+package passive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+func TestIcingaCheckResultObject(t *testing.T) {
+	type testCase struct {
+		have        IcingaCheckResult
+		wantHost    string
+		wantService string
+		wantError   bool
+	}
+
+	testCases := map[string]testCase{
+		"empty": testCase{
+			wantError: true,
+		},
+		"service parameter": testCase{
+			have:        IcingaCheckResult{Service: "example.com!ping"},
+			wantHost:    "example.com",
+			wantService: "ping",
+		},
+		"host parameter": testCase{
+			have:     IcingaCheckResult{Host: "example.com"},
+			wantHost: "example.com",
+		},
+		"service filter": testCase{
+			have: IcingaCheckResult{
+				Type:   "Service",
+				Filter: `host.name=="example.com" && service.name=="ping"`,
+			},
+			wantHost:    "example.com",
+			wantService: "ping",
+		},
+		"host filter": testCase{
+			have: IcingaCheckResult{
+				Type:   "Host",
+				Filter: `host.name == "example.com"`,
+			},
+			wantHost: "example.com",
+		},
+		"service type without service": testCase{
+			have: IcingaCheckResult{
+				Type:   "Service",
+				Filter: `host.name=="example.com"`,
+			},
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			host, service, err := tc.have.Object()
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantHost, host)
+			assert.Equal(t, tc.wantService, service)
+		})
+	}
+}
+
+func TestIcingaCheckResultReceived(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	type testCase struct {
+		have float64
+		want time.Time
+	}
+
+	testCases := map[string]testCase{
+		"unset": testCase{
+			want: now,
+		},
+		"past": testCase{
+			have: 1699999940.5,
+			want: time.Unix(1699999940, 5e8),
+		},
+		"future": testCase{
+			have: 1700086400,
+			want: now,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			exitStatus := 0.0
+			subject := &IcingaCheckResult{Host: "example.com", ExitStatus: &exitStatus, ExecutionEnd: tc.have}
+			got, err := subject.Result(now)
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got.Received)
+		})
+	}
+}
+
+func TestIcingaHandler(t *testing.T) {
+	type testCase struct {
+		method     string
+		query      string
+		body       string
+		wantCode   int
+		wantStatus nagios.ExitCode
+		wantOutput string
+		wantPerf   int
+	}
+
+	testCases := map[string]testCase{
+		"method not allowed": testCase{
+			method:   http.MethodGet,
+			query:    "?service=example.com!ping",
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		"malformed body": testCase{
+			query:    "?service=example.com!ping",
+			body:     `{`,
+			wantCode: http.StatusBadRequest,
+		},
+		"missing exit status": testCase{
+			query:    "?service=example.com!ping",
+			body:     `{"plugin_output": "PING OK"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"invalid exit status": testCase{
+			query:    "?service=example.com!ping",
+			body:     `{"exit_status": 4}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"fractional exit status": testCase{
+			query:    "?service=example.com!ping",
+			body:     `{"exit_status": 1.5}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"missing object": testCase{
+			body:     `{"exit_status": 0}`,
+			wantCode: http.StatusNotFound,
+		},
+		"malformed perfdata": testCase{
+			query:    "?service=example.com!ping",
+			body:     `{"exit_status": 0, "performance_data": ["=1"]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"query object": testCase{
+			query:      "?service=example.com!ping",
+			body:       `{"exit_status": 2, "plugin_output": "PING CRITICAL - Packet loss = 100%", "performance_data": ["rta=0.5ms;100;200", "pl=100%;20;60"]}`,
+			wantCode:   http.StatusOK,
+			wantStatus: nagios.CRITICAL,
+			wantOutput: "PING CRITICAL - Packet loss = 100%",
+			wantPerf:   2,
+		},
+		"filter object": testCase{
+			body:       `{"type": "Service", "filter": "host.name==\"example.com\" && service.name==\"ping\"", "exit_status": 1, "plugin_output": "PING WARNING|rta=0.5ms", "performance_data": "pl=10%"}`,
+			wantCode:   http.StatusOK,
+			wantStatus: nagios.WARNING,
+			wantOutput: "PING WARNING",
+			wantPerf:   2,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			store := NewStore(time.Minute, time.Hour, 0)
+			subject := NewIcingaHandler(store, log.NewNopLogger())
+			req := httptest.NewRequest(method, "/v1/actions/process-check-result"+tc.query, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+
+			subject.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+
+			got, ok := store.Get("example.com!ping")
+			assert.Assert(t, ok)
+			assert.Equal(t, tc.wantStatus, got.Result.Status)
+			assert.Equal(t, tc.wantOutput, got.Result.Output)
+			assert.Equal(t, tc.wantPerf, len(got.Result.PerfData))
+		})
+	}
+}
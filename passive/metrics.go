@@ -0,0 +1,94 @@
+package passive
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exposes the results of a Store as Prometheus metrics
+type Collector struct {
+	store *Store
+	now   func() time.Time
+
+	exitCodeDesc  *prometheus.Desc
+	freshDesc     *prometheus.Desc
+	timestampDesc *prometheus.Desc
+	perfDataDesc  *prometheus.Desc
+}
+
+// NewCollector creates a new collector for the results in the given store
+func NewCollector(namespace string, store *Store) *Collector {
+	labels := []string{"host", "service"}
+	result := &Collector{
+		store: store,
+		now:   time.Now,
+		exitCodeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "passive", "exit_code"),
+			"Exit code of the last passive check result; UNKNOWN once the result is stale",
+			labels, nil,
+		),
+		freshDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "passive", "result_fresh"),
+			"Displays whether or not the last passive check result is within its freshness threshold",
+			labels, nil,
+		),
+		timestampDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "passive", "last_result_timestamp_seconds"),
+			"Timestamp of the last passive check result",
+			labels, nil,
+		),
+		perfDataDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "passive", "perfdata_value"),
+			"Performance data value of the last fresh passive check result",
+			append(labels, "label", "unit"), nil,
+		),
+	}
+
+	return result
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.exitCodeDesc
+	ch <- c.freshDesc
+	ch <- c.timestampDesc
+	ch <- c.perfDataDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	freshness := c.store.Freshness()
+
+	c.store.Expire()
+	c.store.Visit(func(r *Result) {
+		var fresh float64
+		if r.Fresh(now, freshness) {
+			fresh = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.exitCodeDesc, prometheus.GaugeValue,
+			float64(r.Status(now, freshness)), r.Host, r.Service)
+		ch <- prometheus.MustNewConstMetric(c.freshDesc, prometheus.GaugeValue,
+			fresh, r.Host, r.Service)
+		ch <- prometheus.MustNewConstMetric(c.timestampDesc, prometheus.GaugeValue,
+			float64(r.Received.UnixNano())/1e9, r.Host, r.Service)
+
+		if fresh == 0 {
+			return
+		}
+
+		seen := make(map[string]bool, len(r.Result.PerfData))
+		for _, p := range r.Result.PerfData {
+			// duplicate labels would render the whole scrape invalid
+			if seen[p.Label()] {
+				continue
+			}
+			seen[p.Label()] = true
+
+			ch <- prometheus.MustNewConstMetric(c.perfDataDesc, prometheus.GaugeValue,
+				p.Float(), r.Host, r.Service, p.Label(), p.Unit())
+		}
+	})
+}
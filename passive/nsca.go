@@ -0,0 +1,337 @@
+package passive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+const (
+	// NSCASource is the source name of results submitted via NSCA
+	NSCASource = "nsca"
+
+	nscaPacketVersion = 3
+	nscaIVSize        = 128
+	nscaHostSize      = 64
+	nscaServiceSize   = 128
+	// NSCA < 2.9 limits the plugin output to 512 bytes, later
+	// versions to 4096 bytes. both sizes are accepted by the server.
+	nscaLegacyOutputSize = 512
+	nscaOutputSize       = 4096
+	// offset of the plugin output in the C struct; includes the
+	// alignment padding after the packet version
+	nscaHeaderSize = 2 + 2 + 4 + 4 + 2 + nscaHostSize + nscaServiceSize
+)
+
+var (
+	nscaLegacyPacketSize = nscaPacketSize(nscaLegacyOutputSize)
+	nscaFullPacketSize   = nscaPacketSize(nscaOutputSize)
+
+	errNSCAChecksum = errors.New("NSCA packet checksum mismatch")
+)
+
+// NSCAEncryption is the payload encryption method of the NSCA protocol
+type NSCAEncryption int
+
+const (
+	NSCAEncryptionNone NSCAEncryption = 0
+	NSCAEncryptionXOR  NSCAEncryption = 1
+)
+
+// ParseNSCAEncryption returns the encryption method for the given name
+// or numeric identifier (as used in the nsca.cfg)
+func ParseNSCAEncryption(s string) (NSCAEncryption, error) {
+	switch strings.ToLower(s) {
+	case "", "0", "none":
+		return NSCAEncryptionNone, nil
+	case "1", "xor":
+		return NSCAEncryptionXOR, nil
+	}
+
+	return NSCAEncryptionNone, fmt.Errorf("Unsupported NSCA encryption method %q", s)
+}
+
+// nscaPacketSize returns the size of the C struct including
+// its trailing alignment padding
+func nscaPacketSize(outputSize int) int {
+	size := nscaHeaderSize + outputSize
+	if r := size % 4; r != 0 {
+		size += 4 - r
+	}
+
+	return size
+}
+
+// NSCAPacket is the data packet submitted by send_nsca
+type NSCAPacket struct {
+	Timestamp  uint32
+	ReturnCode int16
+	Host       string
+	Service    string
+	Output     string
+}
+
+// MarshalBinary encodes the packet using the layout of the
+// given plugin output size. The checksum is calculated as well.
+func (p *NSCAPacket) MarshalBinary(outputSize int) ([]byte, error) {
+	if len(p.Host) >= nscaHostSize || len(p.Service) >= nscaServiceSize || len(p.Output) >= outputSize {
+		return nil, fmt.Errorf("NSCA packet field exceeds the maximum length")
+	}
+
+	buf := make([]byte, nscaPacketSize(outputSize))
+	binary.BigEndian.PutUint16(buf[0:], nscaPacketVersion)
+	binary.BigEndian.PutUint32(buf[8:], p.Timestamp)
+	binary.BigEndian.PutUint16(buf[12:], uint16(p.ReturnCode))
+	copy(buf[14:], p.Host)
+	copy(buf[14+nscaHostSize:], p.Service)
+	copy(buf[nscaHeaderSize:], p.Output)
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(buf))
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes the given (already decrypted) data
+// and verifies its version and checksum
+func (p *NSCAPacket) UnmarshalBinary(data []byte) error {
+	if len(data) < nscaLegacyPacketSize {
+		return fmt.Errorf("NSCA packet is too short (%d bytes)", len(data))
+	}
+
+	if v := binary.BigEndian.Uint16(data[0:]); v != nscaPacketVersion {
+		return fmt.Errorf("Unsupported NSCA packet version %d", v)
+	}
+
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	crc := binary.BigEndian.Uint32(buf[4:])
+	binary.BigEndian.PutUint32(buf[4:], 0)
+	if crc32.ChecksumIEEE(buf) != crc {
+		return errNSCAChecksum
+	}
+
+	p.Timestamp = binary.BigEndian.Uint32(data[8:])
+	p.ReturnCode = int16(binary.BigEndian.Uint16(data[12:]))
+	p.Host = nscaString(data[14 : 14+nscaHostSize])
+	p.Service = nscaString(data[14+nscaHostSize : nscaHeaderSize])
+	p.Output = nscaString(data[nscaHeaderSize:])
+
+	return nil
+}
+
+func nscaString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+// NSCACipher applies the NSCA payload encryption
+type NSCACipher struct {
+	method   NSCAEncryption
+	iv       []byte
+	password []byte
+}
+
+// NewNSCACipher creates a new cipher using the given method,
+// initialization vector, and password
+func NewNSCACipher(method NSCAEncryption, iv, password []byte) *NSCACipher {
+	result := &NSCACipher{
+		method:   method,
+		iv:       iv,
+		password: password,
+	}
+
+	return result
+}
+
+// XORKeyStream encrypts or decrypts the given buffer in place. The
+// offset denotes the position of the buffer within the packet.
+func (c *NSCACipher) XORKeyStream(buf []byte, offset int) {
+	if c.method != NSCAEncryptionXOR {
+		return
+	}
+
+	for i := range buf {
+		if len(c.iv) > 0 {
+			buf[i] ^= c.iv[(offset+i)%len(c.iv)]
+		}
+		if len(c.password) > 0 {
+			buf[i] ^= c.password[(offset+i)%len(c.password)]
+		}
+	}
+}
+
+// NSCAServer accepts passive check results from send_nsca clients
+type NSCAServer struct {
+	store        *Store
+	logger       log.Logger
+	method       NSCAEncryption
+	password     []byte
+	maxPacketAge time.Duration
+	timeout      time.Duration
+	now          func() time.Time
+}
+
+// NewNSCAServer creates a new server, submitting the received
+// check results to the given store. Packets older than maxPacketAge
+// are discarded; a non-positive value disables the check.
+func NewNSCAServer(store *Store, logger log.Logger, method NSCAEncryption, password []byte, maxPacketAge time.Duration) *NSCAServer {
+	result := &NSCAServer{
+		store:        store,
+		logger:       logger,
+		method:       method,
+		password:     password,
+		maxPacketAge: maxPacketAge,
+		timeout:      10 * time.Second,
+		now:          time.Now,
+	}
+
+	return result
+}
+
+// ListenAndServe listens on the given TCP address and serves
+// incoming connections
+func (s *NSCAServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the given listener until it is closed
+func (s *NSCAServer) Serve(l net.Listener) error {
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *NSCAServer) handle(conn net.Conn) {
+	defer conn.Close()
+	logger := log.With(s.logger, "remote", conn.RemoteAddr())
+
+	now := s.now()
+	init := make([]byte, nscaIVSize+4)
+	if _, err := rand.Read(init[:nscaIVSize]); err != nil {
+		level.Error(logger).Log("msg", "Unable to generate NSCA initialization vector", "err", err)
+		return
+	}
+	binary.BigEndian.PutUint32(init[nscaIVSize:], uint32(now.Unix()))
+
+	conn.SetWriteDeadline(now.Add(s.timeout))
+	if _, err := conn.Write(init); err != nil {
+		level.Debug(logger).Log("msg", "Unable to send NSCA initialization packet", "err", err)
+		return
+	}
+
+	cipher := NewNSCACipher(s.method, init[:nscaIVSize], s.password)
+	for {
+		conn.SetReadDeadline(s.now().Add(s.timeout))
+		packet, err := s.readPacket(conn, cipher)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			level.Warn(logger).Log("msg", "Rejecting NSCA connection", "err", err)
+			return
+		}
+
+		result, err := s.result(packet)
+		if err != nil {
+			level.Warn(logger).Log("msg", "Rejecting NSCA check result", "host", packet.Host, "service", packet.Service, "err", err)
+			continue
+		}
+
+		if err := s.store.Submit(result); err != nil {
+			level.Warn(logger).Log("msg", "Rejecting NSCA check result", "object", result.Name(), "err", err)
+			continue
+		}
+		level.Debug(logger).Log("msg", "Received passive check result", "object", result.Name(), "source", result.Source, "nagios_result", result.Result.Status)
+	}
+}
+
+// readPacket reads a single data packet. Legacy sized packets are
+// tried first; should their checksum not match, the remainder of
+// a full sized packet is read.
+func (s *NSCAServer) readPacket(r io.Reader, cipher *NSCACipher) (*NSCAPacket, error) {
+	buf := make([]byte, nscaFullPacketSize)
+
+	if _, err := io.ReadFull(r, buf[:nscaLegacyPacketSize]); err != nil {
+		return nil, err
+	}
+	cipher.XORKeyStream(buf[:nscaLegacyPacketSize], 0)
+
+	packet := &NSCAPacket{}
+	err := packet.UnmarshalBinary(buf[:nscaLegacyPacketSize])
+	if err != errNSCAChecksum {
+		return packet, err
+	}
+
+	if _, err := io.ReadFull(r, buf[nscaLegacyPacketSize:]); err != nil {
+		return nil, err
+	}
+	cipher.XORKeyStream(buf[nscaLegacyPacketSize:], nscaLegacyPacketSize)
+
+	if err := packet.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+
+	return packet, nil
+}
+
+func (s *NSCAServer) result(p *NSCAPacket) (*Result, error) {
+	received := time.Unix(int64(p.Timestamp), 0)
+	if s.maxPacketAge > 0 {
+		if age := s.now().Sub(received); age > s.maxPacketAge || age < -s.maxPacketAge {
+			return nil, fmt.Errorf("NSCA packet age of %s exceeds the limit", age)
+		}
+	}
+
+	if p.Host == "" {
+		return nil, errMissingObject
+	}
+
+	output := &nagios.PluginResult{}
+	if err := nagios.NewPluginResultDecoder(strings.NewReader(p.Output)).Decode(output); err != nil {
+		return nil, err
+	}
+
+	if !validExitStatus(float64(p.ReturnCode)) {
+		return nil, fmt.Errorf("Invalid NSCA return code %d", p.ReturnCode)
+	}
+	output.Status = nagios.ExitCode(p.ReturnCode)
+
+	result := &Result{
+		Host:     p.Host,
+		Service:  p.Service,
+		Source:   NSCASource,
+		Received: s.now(),
+		Result:   output,
+	}
+
+	return result, nil
+}
@@ -0,0 +1,207 @@
+package passive
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// sendNSCA mimics the send_nsca client
+func sendNSCA(t *testing.T, addr string, method NSCAEncryption, password string, outputSize int, packets ...*NSCAPacket) {
+	conn, err := net.Dial("tcp", addr)
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	init := make([]byte, nscaIVSize+4)
+	_, err = io.ReadFull(conn, init)
+	assert.NilError(t, err)
+
+	cipher := NewNSCACipher(method, init[:nscaIVSize], []byte(password))
+	for _, p := range packets {
+		if p.Timestamp == 0 {
+			p.Timestamp = binary.BigEndian.Uint32(init[nscaIVSize:])
+		}
+
+		buf, err := p.MarshalBinary(outputSize)
+		assert.NilError(t, err)
+
+		cipher.XORKeyStream(buf, 0)
+		_, err = conn.Write(buf)
+		assert.NilError(t, err)
+	}
+}
+
+func waitForResult(store *Store, name string) (*Result, bool) {
+	for i := 0; i < 100; i++ {
+		if r, ok := store.Get(name); ok {
+			return r, ok
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return nil, false
+}
+
+func TestParseNSCAEncryption(t *testing.T) {
+	type testCase struct {
+		have      string
+		want      NSCAEncryption
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"empty":   testCase{have: "", want: NSCAEncryptionNone},
+		"none":    testCase{have: "none", want: NSCAEncryptionNone},
+		"numeric": testCase{have: "1", want: NSCAEncryptionXOR},
+		"xor":     testCase{have: "XOR", want: NSCAEncryptionXOR},
+		"3des":    testCase{have: "3", wantError: true},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, err := ParseNSCAEncryption(tc.have)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNSCAPacketRoundtrip(t *testing.T) {
+	have := &NSCAPacket{
+		Timestamp:  1700000000,
+		ReturnCode: 2,
+		Host:       "example.com",
+		Service:    "backup",
+		Output:     "BACKUP CRITICAL|files=0",
+	}
+
+	for _, size := range []int{nscaLegacyOutputSize, nscaOutputSize} {
+		buf, err := have.MarshalBinary(size)
+		assert.NilError(t, err)
+		assert.Equal(t, nscaPacketSize(size), len(buf))
+
+		got := &NSCAPacket{}
+		assert.NilError(t, got.UnmarshalBinary(buf))
+		assert.DeepEqual(t, have, got)
+
+		buf[len(buf)-1] ^= 0xff
+		assert.ErrorIs(t, got.UnmarshalBinary(buf), errNSCAChecksum)
+	}
+}
+
+func TestNSCAServer(t *testing.T) {
+	type testCase struct {
+		method       NSCAEncryption
+		password     string
+		sendPassword string
+		outputSize   int
+		timestamp    uint32
+		returnCode   int16
+		want         bool
+	}
+
+	testCases := map[string]testCase{
+		"unencrypted": testCase{
+			outputSize: nscaOutputSize,
+			want:       true,
+		},
+		"unencrypted legacy": testCase{
+			outputSize: nscaLegacyOutputSize,
+			want:       true,
+		},
+		"xor": testCase{
+			method:       NSCAEncryptionXOR,
+			password:     "secret",
+			sendPassword: "secret",
+			outputSize:   nscaOutputSize,
+			want:         true,
+		},
+		"xor legacy": testCase{
+			method:       NSCAEncryptionXOR,
+			password:     "secret",
+			sendPassword: "secret",
+			outputSize:   nscaLegacyOutputSize,
+			want:         true,
+		},
+		"xor without password": testCase{
+			method:     NSCAEncryptionXOR,
+			outputSize: nscaLegacyOutputSize,
+			want:       true,
+		},
+		"xor password mismatch": testCase{
+			method:       NSCAEncryptionXOR,
+			password:     "secret",
+			sendPassword: "wrong",
+			outputSize:   nscaLegacyOutputSize,
+		},
+		"expired packet": testCase{
+			outputSize: nscaLegacyOutputSize,
+			timestamp:  1,
+		},
+		"invalid return code": testCase{
+			outputSize: nscaOutputSize,
+			returnCode: 7,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			store := NewStore(time.Minute, time.Hour, 0)
+			subject := NewNSCAServer(store, log.NewNopLogger(), tc.method, []byte(tc.password), 30*time.Second)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+			defer l.Close()
+			go subject.Serve(l)
+
+			returnCode := int16(1)
+			if tc.returnCode != 0 {
+				returnCode = tc.returnCode
+			}
+
+			sendNSCA(t, l.Addr().String(), tc.method, tc.sendPassword, tc.outputSize,
+				&NSCAPacket{
+					Timestamp:  tc.timestamp,
+					ReturnCode: returnCode,
+					Host:       "example.com",
+					Service:    "backup",
+					Output:     "BACKUP WARNING - 2 files skipped|files=1024;;;0",
+				},
+				&NSCAPacket{
+					Timestamp: tc.timestamp,
+					Host:      "example.com",
+					Output:    "UP",
+				},
+			)
+
+			got, ok := waitForResult(store, "example.com!backup")
+			assert.Equal(t, tc.want, ok)
+			if !tc.want {
+				return
+			}
+
+			assert.Equal(t, nagios.WARNING, got.Result.Status)
+			assert.Equal(t, "BACKUP WARNING - 2 files skipped", got.Result.Output)
+			assert.Equal(t, 1, len(got.Result.PerfData))
+			assert.Equal(t, NSCASource, got.Source)
+
+			got, ok = waitForResult(store, "example.com")
+			assert.Assert(t, ok)
+			assert.Equal(t, nagios.OK, got.Result.Status)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package passive
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+// ObjectDelimiter separates the host and service name
+// in a check result object name
+const ObjectDelimiter = "!"
+
+// ErrStoreFull is returned when submitting results for a new
+// object while the store already holds the maximum number of objects
+var ErrStoreFull = errors.New("Maximum number of passive check result objects reached")
+
+// Result is a check result submitted by an external party
+type Result struct {
+	Host     string
+	Service  string
+	Source   string
+	Received time.Time
+	TTL      time.Duration
+	Result   *nagios.PluginResult
+}
+
+// Name returns the object name of the result, using the
+// Icinga notation of host!service (or just host for host checks)
+func (r *Result) Name() string {
+	if r.Service == "" {
+		return r.Host
+	}
+
+	return r.Host + ObjectDelimiter + r.Service
+}
+
+// Fresh reports whether the result is still considered to be current.
+// The result TTL takes precedence over the provided freshness threshold.
+// A non-positive threshold disables the freshness check.
+func (r *Result) Fresh(now time.Time, freshness time.Duration) bool {
+	if r.TTL > 0 {
+		freshness = r.TTL
+	}
+
+	if freshness <= 0 {
+		return true
+	}
+
+	return now.Sub(r.Received) <= freshness
+}
+
+// Status returns the result exit code, or UNKNOWN if the
+// result is no longer fresh
+func (r *Result) Status(now time.Time, freshness time.Duration) nagios.ExitCode {
+	if !r.Fresh(now, freshness) {
+		return nagios.UNKNOWN
+	}
+
+	return r.Result.Status
+}
+
+// Expired reports whether the result has not been fresh for longer
+// than the given retention period. Results which never become stale
+// never expire.
+func (r *Result) Expired(now time.Time, freshness, retention time.Duration) bool {
+	if r.TTL > 0 {
+		freshness = r.TTL
+	}
+
+	if freshness <= 0 {
+		return false
+	}
+
+	return now.Sub(r.Received) > freshness+retention
+}
+
+// Store is a thread-safe container for passive check results
+type Store struct {
+	sync.RWMutex
+	results   map[string]*Result
+	freshness time.Duration
+	retention time.Duration
+	limit     int
+	now       func() time.Time
+}
+
+// NewStore creates a new result store. Results older than
+// the given freshness threshold are considered stale and are
+// dropped once they have been stale for the retention period.
+// The number of stored objects is capped by the given limit;
+// a non-positive limit disables the cap.
+func NewStore(freshness, retention time.Duration, limit int) *Store {
+	result := &Store{
+		results:   make(map[string]*Result),
+		freshness: freshness,
+		retention: retention,
+		limit:     limit,
+		now:       time.Now,
+	}
+
+	return result
+}
+
+// Freshness returns the configured freshness threshold
+func (s *Store) Freshness() time.Duration {
+	return s.freshness
+}
+
+// Submit adds the given result to the store, replacing any
+// previous result for the same object. Results for new objects
+// are rejected with ErrStoreFull if the store limit is reached.
+func (s *Store) Submit(r *Result) error {
+	name := r.Name()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.results[name]; !ok && s.limit > 0 && len(s.results) >= s.limit {
+		s.expire()
+
+		if len(s.results) >= s.limit {
+			return ErrStoreFull
+		}
+	}
+
+	s.results[name] = r
+
+	return nil
+}
+
+// Expire drops all results which have been stale
+// for longer than the retention period
+func (s *Store) Expire() {
+	s.Lock()
+	s.expire()
+	s.Unlock()
+}
+
+func (s *Store) expire() {
+	now := s.now()
+	for name, r := range s.results {
+		if r.Expired(now, s.freshness, s.retention) {
+			delete(s.results, name)
+		}
+	}
+}
+
+// Get returns the result for the given object name, if any
+func (s *Store) Get(name string) (*Result, bool) {
+	s.RLock()
+	r, ok := s.results[name]
+	s.RUnlock()
+
+	return r, ok
+}
+
+// Visit calls the visitor for every stored result,
+// ordered by object name
+func (s *Store) Visit(visitor func(*Result)) {
+	s.RLock()
+	results := make([]*Result, 0, len(s.results))
+	for _, r := range s.results {
+		results = append(results, r)
+	}
+	s.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name() < results[j].Name()
+	})
+
+	for _, r := range results {
+		visitor(r)
+	}
+}
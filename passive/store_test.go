@@ -0,0 +1,118 @@
+package passive
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+)
+
+func TestResultStatus(t *testing.T) {
+	type testCase struct {
+		age       time.Duration
+		ttl       time.Duration
+		freshness time.Duration
+		want      nagios.ExitCode
+	}
+
+	testCases := map[string]testCase{
+		"fresh": testCase{
+			age:       time.Minute,
+			freshness: 5 * time.Minute,
+			want:      nagios.WARNING,
+		},
+		"stale": testCase{
+			age:       10 * time.Minute,
+			freshness: 5 * time.Minute,
+			want:      nagios.UNKNOWN,
+		},
+		"freshness disabled": testCase{
+			age:  10 * time.Hour,
+			want: nagios.WARNING,
+		},
+		"ttl extends freshness": testCase{
+			age:       10 * time.Minute,
+			ttl:       time.Hour,
+			freshness: 5 * time.Minute,
+			want:      nagios.WARNING,
+		},
+		"ttl shortens freshness": testCase{
+			age:       2 * time.Minute,
+			ttl:       time.Minute,
+			freshness: 5 * time.Minute,
+			want:      nagios.UNKNOWN,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			now := time.Now()
+			subject := &Result{
+				Host:     "localhost",
+				Received: now.Add(-tc.age),
+				TTL:      tc.ttl,
+				Result: &nagios.PluginResult{
+					Status: nagios.WARNING,
+				},
+			}
+
+			got := subject.Status(now, tc.freshness)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStoreVisit(t *testing.T) {
+	subject := NewStore(time.Minute, time.Hour, 0)
+	subject.Submit(&Result{Host: "b", Service: "svc"})
+	subject.Submit(&Result{Host: "a"})
+	subject.Submit(&Result{Host: "a", Service: "svc"})
+	subject.Submit(&Result{Host: "b", Service: "svc", Source: "replaced"})
+
+	got := []string{}
+	subject.Visit(func(r *Result) {
+		got = append(got, r.Name()+r.Source)
+	})
+
+	assert.DeepEqual(t, []string{"a", "a!svc", "b!svcreplaced"}, got)
+}
+
+func TestStoreSubmit(t *testing.T) {
+	now := time.Now()
+	subject := NewStore(time.Minute, time.Hour, 2)
+	subject.now = func() time.Time { return now }
+
+	assert.NilError(t, subject.Submit(&Result{Host: "a", Received: now.Add(-2 * time.Hour)}))
+	assert.NilError(t, subject.Submit(&Result{Host: "b", Received: now}))
+	assert.NilError(t, subject.Submit(&Result{Host: "b", Received: now, Source: "replaced"}))
+	// the result of "a" expired and makes room for "c"
+	assert.NilError(t, subject.Submit(&Result{Host: "c", Received: now}))
+	assert.ErrorIs(t, subject.Submit(&Result{Host: "d", Received: now}), ErrStoreFull)
+
+	_, ok := subject.Get("a")
+	assert.Assert(t, !ok)
+	_, ok = subject.Get("d")
+	assert.Assert(t, !ok)
+}
+
+func TestStoreExpire(t *testing.T) {
+	now := time.Now()
+	subject := NewStore(time.Minute, time.Hour, 0)
+	subject.now = func() time.Time { return now }
+	subject.Submit(&Result{Host: "expired", Received: now.Add(-2 * time.Hour)})
+	subject.Submit(&Result{Host: "stale", Received: now.Add(-30 * time.Minute)})
+	subject.Submit(&Result{Host: "ttl", Received: now.Add(-2 * time.Hour), TTL: 3 * time.Hour})
+	subject.Submit(&Result{Host: "fresh", Received: now})
+
+	subject.Expire()
+
+	got := []string{}
+	subject.Visit(func(r *Result) {
+		got = append(got, r.Name())
+	})
+
+	assert.DeepEqual(t, []string{"fresh", "stale", "ttl"}, got)
+}
@@ -3,18 +3,21 @@ package prober
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/expfmt"
 
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
 	monitoring "github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/prober/nagios"
 )
 
 func debugModule(buf *bytes.Buffer, name string, module *config.Module) {
 	fmt.Fprintf(buf, "Module configuration:\n")
 
-	data, err := module.MarshalIcinga(name)
+	redacted := module.Redacted()
+	data, err := redacted.MarshalIcinga(name)
 	if err != nil {
 		fmt.Fprintf(buf, "Error marshalling config: %s\n", err)
 	}
@@ -23,7 +26,36 @@ func debugModule(buf *bytes.Buffer, name string, module *config.Module) {
 	buf.WriteByte('\n')
 }
 
-func debugPlugin(buf *bytes.Buffer, plugin *monitoring.Plugin, output *monitoring.PluginResult, err error) {
+func debugVariables(buf *bytes.Buffer, module *config.Module, provider func(string) []string, data *nagios.PluginBuilderContext) {
+	fmt.Fprintf(buf, "Variables:\n")
+
+	names := make([]string, 0, len(module.Variables))
+	for name := range module.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values, ok := data.Vars[name]
+		if !ok {
+			fmt.Fprintf(buf, "%s: (unset)\n", name)
+			continue
+		}
+
+		source := "default"
+		if len(monitoring.Compact(provider(name))) > 0 {
+			source = "request"
+		}
+
+		if module.Variables[name].Secret {
+			values = []string{config.SecretToken}
+		}
+
+		fmt.Fprintf(buf, "%s: %q (%s)\n", name, values, source)
+	}
+}
+
+func debugPlugin(buf *bytes.Buffer, plugin monitoring.Runner, output *monitoring.PluginResult, err error) {
 	fmt.Fprintf(buf, "Plugin execution:\n")
 
 	fmt.Fprintf(buf, "Execv: %s\n", plugin)
@@ -3,6 +3,7 @@ package prober
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -19,8 +20,15 @@ import (
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
 )
 
+// Reasons of rejected probe requests
+const (
+	RejectInvalidVariable = "invalid_variable"
+	RejectMissingArgument = "missing_argument"
+)
+
 type Handler struct {
 	namespace     string
+	rejected      *prometheus.CounterVec
 	cache         *template.TemplateCache
 	logger        log.Logger
 	logLevel      level.Option
@@ -28,9 +36,12 @@ type Handler struct {
 	timeoutOffset float64
 }
 
-func NewHandler(namespace string, cache *template.TemplateCache, logger log.Logger, logLevel level.Option, debug bool, timeoutOffset float64) *Handler {
+// NewHandler creates a probe handler. Rejected requests are counted
+// using the given counter, labeled by module and reason.
+func NewHandler(namespace string, cache *template.TemplateCache, rejected *prometheus.CounterVec, logger log.Logger, logLevel level.Option, debug bool, timeoutOffset float64) *Handler {
 	result := &Handler{
 		namespace:     namespace,
+		rejected:      rejected,
 		logger:        logger,
 		logLevel:      logLevel,
 		cache:         cache,
@@ -54,12 +65,27 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := nagios.NewLazyPluginBuilderContext(module.Variables, module.Environment).VisitVariables(nagios.MapVarsProvider(r.URL.Query())).VisitEnvironment(os.Getenv)
+	query := nagios.SplitVarsProvider(module.Variables, nagios.OverridableVarsProvider(module.Variables, nagios.MapVarsProvider(r.URL.Query())))
+	if err := nagios.CheckVariables(module.Variables, query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		level.Debug(h.logger).Log("msg", "Rejected probe request", "module", moduleName, "err", err)
+		h.rejected.WithLabelValues(moduleName, RejectInvalidVariable).Inc()
+		return
+	}
+
+	data := nagios.NewLazyPluginBuilderContext(module.Variables, module.Environment).VisitVariables(query).VisitEnvironment(os.Getenv)
 
+	redactor := nagios.NewRedactor(module, data)
 	metrics := nagios.NewPluginMetrics(module, h.namespace)
 	builder := nagios.NewPluginBuilder(h.cache)
 	prober, err := builder.Build(module, data)
-	if err != nil {
+	var missing *nagios.RequiredArgumentError
+	if errors.As(err, &missing) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		level.Debug(h.logger).Log("msg", "Rejected probe request", "module", moduleName, "err", err)
+		h.rejected.WithLabelValues(moduleName, RejectMissingArgument).Inc()
+		return
+	} else if err != nil {
 		http.Error(w, fmt.Sprintf("Unable to create module probe %q", moduleName), http.StatusInternalServerError)
 		level.Error(h.logger).Log("msg", "Unable to create module probe", "module", moduleName, "err", err)
 		return
@@ -93,7 +119,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 			level.Info(logger).Log("msg", "Probe succeeded", "duration_seconds", duration)
 		}
 
-		level.Debug(logger).Log("msg", output.Output, "nagios_result", output.Status)
+		level.Debug(logger).Log("msg", redactor.Replace(output.Output), "nagios_result", output.Status)
 	}
 
 	metrics.Report(output, err, duration)
@@ -109,6 +135,8 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 
 		debugModule(buf, moduleName, module)
 		buf.WriteByte('\n')
+		debugVariables(buf, module, query, data)
+		buf.WriteByte('\n')
 		debugPlugin(buf, prober, output, err)
 		buf.WriteByte('\n')
 		debugRegistry(buf, registry)
@@ -116,7 +144,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		debugLogger(buf, logger)
 
 		w.Header().Set("Content-Type", "text/plain")
-		w.Write(buf.Bytes())
+		w.Write([]byte(redactor.Replace(buf.String())))
 		return
 	}
 
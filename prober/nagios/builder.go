@@ -3,15 +3,90 @@ package nagios
 import (
 	"cmp"
 	"errors"
+	"fmt"
 	"slices"
+	"sort"
 	"strings"
 
+	promconfig "github.com/prometheus/common/config"
+
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
 	monitoring "github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nrpe"
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
 )
 
-var errMissingCommand = errors.New("Module is missing the plugin command")
+var (
+	errMissingCommand     = errors.New("Module is missing the plugin command")
+	errMissingNRPE        = errors.New("Module is missing the NRPE settings")
+	errMissingNRPEAddress = errors.New("NRPE address rendered to an empty value")
+)
+
+// RequiredArgumentError is returned when building a plugin whose
+// required argument is not set or rendered to an empty value
+type RequiredArgumentError struct {
+	Argument string
+	// Variables are referenced by the argument value or condition
+	// and have no value
+	Variables []string
+	// ConditionFalse is set if the argument is missing as its condition
+	// evaluated to false, although its variables have values
+	ConditionFalse bool
+	// ConditionVariables are referenced by the condition
+	ConditionVariables []string
+}
+
+// Error implements the error interface
+func (e *RequiredArgumentError) Error() string {
+	if e.ConditionFalse {
+		switch len(e.ConditionVariables) {
+		case 0:
+			return fmt.Sprintf("required argument %q is missing, its condition evaluated to false", e.Argument)
+		case 1:
+			return fmt.Sprintf("required argument %q is missing, its condition on variable %q evaluated to false", e.Argument, e.ConditionVariables[0])
+		}
+
+		return fmt.Sprintf("required argument %q is missing, its condition on variables %q evaluated to false", e.Argument, e.ConditionVariables)
+	}
+
+	switch len(e.Variables) {
+	case 0:
+		return fmt.Sprintf("required argument %q is missing", e.Argument)
+	case 1:
+		return fmt.Sprintf("required argument %q is missing, variable %q has no value", e.Argument, e.Variables[0])
+	}
+
+	return fmt.Sprintf("required argument %q is missing, variables %q have no value", e.Argument, e.Variables)
+}
+
+// newRequiredArgumentError creates an error for the given missing argument,
+// naming the referenced variables without value. An explicit condition
+// which evaluated to false is blamed if all of its variables have values.
+func newRequiredArgumentError(key string, arg *config.Argument, condition, macros bool, ctx *PluginBuilderContext) *RequiredArgumentError {
+	result := &RequiredArgumentError{
+		Argument: key,
+	}
+
+	// without explicit condition, the argument is
+	// only omitted if the value is empty
+	condition = condition || arg.Condition == ""
+
+	names := arg.Variables(macros)
+	if !condition {
+		result.ConditionVariables = arg.ConditionVariables(macros)
+		names = result.ConditionVariables
+	}
+
+	for _, name := range names {
+		if len(monitoring.Compact(ctx.Vars[name])) == 0 {
+			result.Variables = append(result.Variables, name)
+		}
+	}
+
+	result.ConditionFalse = !condition && len(result.Variables) == 0
+
+	return result
+}
 
 type PluginBuilder struct {
 	cache *template.TemplateCache
@@ -48,8 +123,8 @@ func JoinKeyValues(m map[string]string, sep string) []string {
 	return result
 }
 
-func (b *PluginBuilder) Build(module *config.Module, ctx *PluginBuilderContext) (*monitoring.Plugin, error) {
-	args, err := b.parseArguments(module.Arguments, ctx)
+func (b *PluginBuilder) Build(module *config.Module, ctx *PluginBuilderContext) (monitoring.Runner, error) {
+	args, err := b.parseArguments(module.Arguments, module.Macros, ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +133,58 @@ func (b *PluginBuilder) Build(module *config.Module, ctx *PluginBuilderContext)
 		return nil, errMissingCommand
 	}
 
-	result := monitoring.NewPlugin(module.Command, args, JoinKeyValues(ctx.Env, "="))
+	var result monitoring.Runner
+	if module.Type == config.ModuleTypeNRPE {
+		result, err = b.buildNRPE(module, args, ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		result = monitoring.NewPlugin(module.Command, args, JoinKeyValues(ctx.Env, "="))
+	}
+
+	return NewRedactor(module, ctx).Runner(result), nil
+}
+
+// NewRedactor creates a redactor for the values of the secret module
+// variables, including those provided by the request
+func NewRedactor(module *config.Module, ctx *PluginBuilderContext) *monitoring.Redactor {
+	var secrets []string
+	for k, v := range module.Variables {
+		if v.Secret {
+			secrets = append(secrets, v.Value...)
+			secrets = append(secrets, ctx.Vars[k]...)
+		}
+	}
+
+	return monitoring.NewRedactor(secrets, config.SecretToken)
+}
+
+func (b *PluginBuilder) buildNRPE(module *config.Module, args []string, ctx *PluginBuilderContext) (monitoring.Runner, error) {
+	if module.NRPE == nil {
+		return nil, errMissingNRPE
+	}
+
+	address, err := b.cache.RenderString("Address", module.NRPE.Address, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil, errMissingNRPEAddress
+	}
+
+	if !module.NRPE.TLS {
+		return nrpe.NewClient(nrpe.JoinHostPort(address), module.Command, args, int16(module.NRPE.Version), nil), nil
+	}
+
+	tlsConfig, err := promconfig.NewTLSConfig(&module.NRPE.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	result := nrpe.NewClient(nrpe.JoinHostPort(address), module.Command, args, int16(module.NRPE.Version), tlsConfig)
 
 	return result, nil
 }
@@ -103,15 +229,26 @@ func renderArguments(argv []*argument) []string {
 	return result
 }
 
-func (b *PluginBuilder) parseArguments(args map[string]config.Argument, ctx *PluginBuilderContext) ([]string, error) {
+func (b *PluginBuilder) parseArguments(args map[string]config.Argument, macros bool, ctx *PluginBuilderContext) ([]string, error) {
 	argv := make([]*argument, 0, len(args))
 
-	for key, arg := range args {
-		item, err := b.parseArgument(&arg, ctx)
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		arg := args[key]
+		item, err := b.parseArgument(&arg, macros, ctx)
 		if err != nil {
 			return nil, err
 		}
 
+		if item.required && (!item.condition || (len(arg.Value) > 0 && len(item.value) == 0)) {
+			return nil, newRequiredArgumentError(key, &arg, item.condition, macros, ctx)
+		}
+
 		if item.key == "" {
 			item.key = key
 		}
@@ -122,7 +259,39 @@ func (b *PluginBuilder) parseArguments(args map[string]config.Argument, ctx *Plu
 	return renderArguments(argv), nil
 }
 
-func (b *PluginBuilder) parseArgument(c *config.Argument, ctx *PluginBuilderContext) (*argument, error) {
+// renderValues renders the given value either as runtime
+// macro expression (if enabled) or as template. Templates yield
+// multiple values by rendering them on individual lines.
+func (b *PluginBuilder) renderValues(name, s string, macros bool, ctx *PluginBuilderContext) ([]string, error) {
+	if macros && isMacroExpression(s) {
+		return ExpandMacros(s, ctx.Vars), nil
+	}
+
+	val, err := b.cache.RenderString(name, s, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(val, "\n"), nil
+}
+
+// renderBool renders the given value either as runtime
+// macro expression (if enabled) or as template
+func (b *PluginBuilder) renderBool(name, s string, macros bool, ctx *PluginBuilderContext) (bool, error) {
+	if macros && isMacroExpression(s) {
+		return ExpandMacrosBool(s, ctx.Vars)
+	}
+
+	return b.cache.RenderBool(name, s, ctx)
+}
+
+// isMacroExpression reports whether the given string uses runtime
+// macros instead of templates; the latter take precedence
+func isMacroExpression(s string) bool {
+	return !strings.Contains(s, template.TemplateToken) && HasMacros(s)
+}
+
+func (b *PluginBuilder) parseArgument(c *config.Argument, macros bool, ctx *PluginBuilderContext) (*argument, error) {
 	var result = &argument{}
 	var err error
 
@@ -132,12 +301,12 @@ func (b *PluginBuilder) parseArgument(c *config.Argument, ctx *PluginBuilderCont
 
 	result.value = make([]string, 0, len(c.Value))
 	for _, v := range c.Value {
-		val, err := b.cache.RenderString("Value", v, ctx)
+		vals, err := b.renderValues("Value", v, macros, ctx)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, v2 := range strings.Split(val, "\n") {
+		for _, v2 := range vals {
 			v2 = strings.TrimSpace(v2)
 			if v2 != "" {
 				result.value = append(result.value, v2)
@@ -145,8 +314,15 @@ func (b *PluginBuilder) parseArgument(c *config.Argument, ctx *PluginBuilderCont
 		}
 	}
 
+	if c.Required != "" {
+		result.required, err = b.renderBool("Required", string(c.Required), macros, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if c.Condition != "" {
-		result.condition, err = b.cache.RenderBool("Condition", string(c.Condition), ctx)
+		result.condition, err = b.renderBool("Condition", string(c.Condition), macros, ctx)
 		// omit rendering the rest if the argument is not used anyway
 		if err != nil || !result.condition {
 			return result, err
@@ -155,15 +331,8 @@ func (b *PluginBuilder) parseArgument(c *config.Argument, ctx *PluginBuilderCont
 		result.condition = len(result.value) > 0
 	}
 
-	if c.Required != "" {
-		result.required, err = b.cache.RenderBool("Required", string(c.Required), ctx)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	if c.RepeatKey != "" {
-		result.repeatKey, err = b.cache.RenderBool("RepeatKey", string(c.RepeatKey), ctx)
+		result.repeatKey, err = b.renderBool("RepeatKey", string(c.RepeatKey), macros, ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -172,7 +341,7 @@ func (b *PluginBuilder) parseArgument(c *config.Argument, ctx *PluginBuilderCont
 	}
 
 	if c.SkipKey != "" {
-		result.skipKey, err = b.cache.RenderBool("SkipKey", string(c.SkipKey), ctx)
+		result.skipKey, err = b.renderBool("SkipKey", string(c.SkipKey), macros, ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -5,6 +5,9 @@ import (
 
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
 )
 
 func TestJoinKeyValues(t *testing.T) {
@@ -83,3 +86,184 @@ func TestRenderArguments(t *testing.T) {
 
 	assert.DeepEqual(t, want, got)
 }
+
+func TestPluginBuilderBuild(t *testing.T) {
+	type testCase struct {
+		have      config.Module
+		want      string
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"missing command": testCase{
+			have:      config.Module{},
+			wantError: true,
+		},
+		"exec": testCase{
+			have: config.Module{
+				Command: "/bin/check_dummy",
+				Arguments: map[string]config.Argument{
+					"STATE": config.Argument{Value: []string{"{{ .Vars.state | first }}"}, SkipKey: "true"},
+				},
+			},
+			want: "/bin/check_dummy 2",
+		},
+		"secret": testCase{
+			have: config.Module{
+				Command: "/bin/check_snmp",
+				Arguments: map[string]config.Argument{
+					"-H": config.Argument{Value: []string{"{{ .Vars.host | first }}"}, Separator: " ", Order: 1},
+					"-C": config.Argument{Value: []string{"{{ .Vars.community | first }}"}, Separator: " ", Order: 2},
+				},
+				Variables: map[string]config.Variable{
+					"community": config.Variable{Value: config.LazyArray{"public"}, Secret: true},
+				},
+			},
+			want: "/bin/check_snmp -H localhost -C <secret>",
+		},
+		"macros": testCase{
+			have: config.Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]config.Argument{
+					"-H":    config.Argument{Value: []string{"$vhosts$"}, RepeatKey: "true", Separator: " ", Order: 1},
+					"-u":    config.Argument{Value: []string{"/$host.vars.state$/$vhosts$"}, Separator: " ", Order: 2},
+					"-p":    config.Argument{Value: []string{"$missing$"}, Separator: " ", Order: 3},
+					"--ssl": config.Argument{Condition: "$ssl$", Order: 4},
+					"--sni": config.Argument{Condition: "$missing$", Order: 5},
+				},
+				Macros: true,
+			},
+			want: "/bin/check_http -H a -H b -u /2/a;b --ssl",
+		},
+		"literal macros": testCase{
+			have: config.Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]config.Argument{
+					"-a": config.Argument{Value: []string{"user:ab$c$d"}, Separator: " "},
+				},
+			},
+			want: "/bin/check_http -a user:ab$c$d",
+		},
+		"nrpe": testCase{
+			have: config.Module{
+				Type:    config.ModuleTypeNRPE,
+				Command: "check_dummy",
+				Arguments: map[string]config.Argument{
+					"STATE": config.Argument{Value: []string{"{{ .Vars.state | first }}"}, SkipKey: "true"},
+				},
+				NRPE: &config.NRPE{
+					Address: "{{ .Vars.host | first }}",
+					Version: 3,
+				},
+			},
+			want: "nrpe://localhost:5666/check_dummy!2",
+		},
+		"nrpe empty address": testCase{
+			have: config.Module{
+				Type:    config.ModuleTypeNRPE,
+				Command: "check_dummy",
+				NRPE: &config.NRPE{
+					Address: `{{ .Vars.missing | join "" }}`,
+				},
+			},
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			data := NewPluginBuilderContext(map[string][]string{
+				"state":     []string{"2"},
+				"host":      []string{"localhost"},
+				"vhosts":    []string{"a", "b"},
+				"ssl":       []string{"1"},
+				"community": []string{"s3cr3t-c0mmunity"},
+			}, map[string]string{})
+			subject := NewPluginBuilder(template.NewFuncMapTemplateCache(template.Functions))
+			got, err := subject.Build(&tc.have, data)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got.String())
+		})
+	}
+}
+
+func TestPluginBuilderBuildRequired(t *testing.T) {
+	type testCase struct {
+		have      config.Argument
+		want      string
+		wantError string
+	}
+
+	testCases := map[string]testCase{
+		"set": testCase{
+			have: config.Argument{Value: []string{"{{ .Vars.host | first }}"}, Required: "true", Separator: " "},
+			want: "/bin/check_http -H localhost",
+		},
+		"optional": testCase{
+			have: config.Argument{Value: []string{"{{ .Vars.missing | join \"\" }}"}, Required: "false"},
+			want: "/bin/check_http",
+		},
+		"empty value": testCase{
+			have:      config.Argument{Value: []string{"{{ .Vars.missing | join \"\" }}"}, Required: "true"},
+			wantError: `required argument "-H" is missing, variable "missing" has no value`,
+		},
+		"macro": testCase{
+			have:      config.Argument{Value: []string{"$missing$"}, Required: "true"},
+			wantError: `required argument "-H" is missing, variable "missing" has no value`,
+		},
+		"false condition": testCase{
+			have:      config.Argument{Condition: "{{ .Vars.ssl | first | eq \"1\" }}", Required: "true"},
+			wantError: `required argument "-H" is missing, its condition on variable "ssl" evaluated to false`,
+		},
+		"false condition variables": testCase{
+			have:      config.Argument{Condition: "{{ and (.Vars.ssl | first | eq \"1\") (.Vars.host | first | ne \"\") }}", Required: "true"},
+			wantError: `required argument "-H" is missing, its condition on variables ["host" "ssl"] evaluated to false`,
+		},
+		"false condition literal": testCase{
+			have:      config.Argument{Condition: "false", Required: "true"},
+			wantError: `required argument "-H" is missing, its condition evaluated to false`,
+		},
+		"unset condition": testCase{
+			have:      config.Argument{Condition: "$missing$", Required: "true"},
+			wantError: `required argument "-H" is missing, variable "missing" has no value`,
+		},
+		"empty value with set variables": testCase{
+			have:      config.Argument{Value: []string{"{{ .Vars.host | first | ne \"localhost\" | ternary \"x\" \"\" }}"}, Required: "true"},
+			wantError: `required argument "-H" is missing`,
+		},
+		"flag": testCase{
+			have: config.Argument{Condition: "true", Required: "true"},
+			want: "/bin/check_http -H",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			module := config.Module{
+				Command:   "/bin/check_http",
+				Arguments: map[string]config.Argument{"-H": tc.have},
+				Macros:    true,
+			}
+			data := NewPluginBuilderContext(map[string][]string{
+				"host": []string{"localhost"},
+				"ssl":  []string{"0"},
+			}, map[string]string{})
+			subject := NewPluginBuilder(template.NewFuncMapTemplateCache(template.Functions))
+			got, err := subject.Build(&module, data)
+
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got.String())
+		})
+	}
+}
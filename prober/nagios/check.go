@@ -0,0 +1,117 @@
+package nagios
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+// CheckError describes a problem with a module detected by PluginBuilder.Check.
+// Argument is empty if the problem is not related to a specific argument.
+type CheckError struct {
+	Module   string
+	Argument string
+	Err      error
+}
+
+func (e *CheckError) Error() string {
+	if e.Argument == "" {
+		return fmt.Sprintf("module %q: %s", e.Module, e.Err)
+	}
+
+	return fmt.Sprintf("module %q argument %q: %s", e.Module, e.Argument, e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// CheckConfig runs Check for every module in the given configuration
+// in alphabetical order and returns all detected problems
+func (b *PluginBuilder) CheckConfig(c *config.Config) []error {
+	names := make([]string, 0, len(c.Modules))
+	for name := range c.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []error
+	for _, name := range names {
+		module := c.Modules[name]
+		result = append(result, b.Check(name, &module)...)
+	}
+
+	return result
+}
+
+// Check parses every template of the given module and renders it
+// using its default variables. Instead of stopping at the first
+// problem, all of them are returned.
+func (b *PluginBuilder) Check(name string, module *config.Module) []error {
+	var result []error
+
+	keys := make([]string, 0, len(module.Arguments))
+	for key := range module.Arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ctx := NewLazyPluginBuilderContext(module.Variables, module.Environment).VisitVariables(MapVarsProvider(nil))
+	for _, key := range keys {
+		arg := module.Arguments[key]
+		errs := b.checkArgument(&arg)
+		if len(errs) == 0 {
+			if _, err := b.parseArgument(&arg, module.Macros, ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		for _, err := range errs {
+			result = append(result, &CheckError{Module: name, Argument: key, Err: err})
+		}
+	}
+
+	if module.NRPE != nil {
+		if err := b.cache.Parse("Address", module.NRPE.Address); err != nil {
+			result = append(result, &CheckError{Module: name, Err: err})
+		}
+	}
+
+	if len(result) > 0 {
+		return result
+	}
+
+	// required arguments are usually provided by the probe requests
+	var missing *RequiredArgumentError
+	if _, err := b.Build(module, ctx); err != nil && !errors.As(err, &missing) {
+		result = append(result, &CheckError{Module: name, Err: err})
+	}
+
+	return result
+}
+
+// checkArgument parses every template of the given argument
+func (b *PluginBuilder) checkArgument(c *config.Argument) []error {
+	var result []error
+
+	templates := make([][2]string, 0, len(c.Value)+4)
+	for _, v := range c.Value {
+		templates = append(templates, [2]string{"Value", v})
+	}
+	templates = append(templates,
+		[2]string{"Condition", string(c.Condition)},
+		[2]string{"Required", string(c.Required)},
+		[2]string{"RepeatKey", string(c.RepeatKey)},
+		[2]string{"SkipKey", string(c.SkipKey)},
+	)
+
+	for _, t := range templates {
+		if err := b.cache.Parse(t[0], t[1]); err != nil {
+			result = append(result, err)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,96 @@
+package nagios
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
+)
+
+func TestPluginBuilderCheck(t *testing.T) {
+	type testCase struct {
+		have config.Module
+		want []string
+	}
+
+	testCases := map[string]testCase{
+		"valid": testCase{
+			have: config.Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]config.Argument{
+					"-H":    config.Argument{Value: []string{"{{ .Vars.host | first }}"}},
+					"-p":    config.Argument{Value: []string{"$port$"}},
+					"--ssl": config.Argument{Condition: "{{ if .Vars.ssl }}true{{ end }}"},
+				},
+				Variables: map[string]config.Variable{
+					"host": config.Variable{Value: config.LazyArray{"localhost"}},
+				},
+			},
+			want: []string{},
+		},
+		"required argument without default": testCase{
+			have: config.Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]config.Argument{
+					"-H": config.Argument{Value: []string{"$host$"}, Required: "true"},
+				},
+				Variables: map[string]config.Variable{
+					"host": config.Variable{Value: config.LazyArray{""}},
+				},
+			},
+			want: []string{},
+		},
+		"missing command": testCase{
+			have: config.Module{},
+			want: []string{
+				`module "test": Module is missing the plugin command`,
+			},
+		},
+		"broken templates": testCase{
+			have: config.Module{
+				Command: "/bin/check_http",
+				Arguments: map[string]config.Argument{
+					"-H": config.Argument{Value: []string{"{{ .Vars.host | frist }}"}},
+					"-u": config.Argument{Value: []string{"/"}, RepeatKey: "{{ .Vars.uri"},
+					"-S": config.Argument{Condition: "{{ .Vars.ssl | first }}"},
+				},
+				Variables: map[string]config.Variable{
+					"ssl": config.Variable{Value: config.LazyArray{"maybe"}},
+				},
+			},
+			want: []string{
+				`module "test" argument "-H": template: Value:1: function "frist" not defined`,
+				`module "test" argument "-S": strconv.ParseBool: parsing "maybe": invalid syntax`,
+				`module "test" argument "-u": template: RepeatKey:1: unclosed action`,
+			},
+		},
+		"nrpe address": testCase{
+			have: config.Module{
+				Type:    config.ModuleTypeNRPE,
+				Command: "check_dummy",
+				NRPE: &config.NRPE{
+					Address: "{{ .Vars.host | first ",
+				},
+			},
+			want: []string{
+				`module "test": template: Address:1: unclosed action`,
+			},
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			subject := NewPluginBuilder(template.NewFuncMapTemplateCache(template.Functions))
+			errs := subject.Check("test", &tc.have)
+
+			got := make([]string, len(errs))
+			for i, err := range errs {
+				got[i] = err.Error()
+			}
+
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
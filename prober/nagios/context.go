@@ -1,7 +1,9 @@
 package nagios
 
 import (
+	"fmt"
 	"os"
+	"sort"
 
 	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
 	monitoring "github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
@@ -26,12 +28,86 @@ func MapVarsProvider(m map[string][]string) func(string) []string {
 	}
 }
 
+// OverridableVarsProvider wraps the given provider, yielding
+// nil for all variables which are not overridable
+func OverridableVarsProvider(vars map[string]config.Variable, provider func(string) []string) func(string) []string {
+	return func(s string) []string {
+		if v, ok := vars[s]; ok && !v.IsOverridable() {
+			return nil
+		}
+
+		return provider(s)
+	}
+}
+
+// SplitVarsProvider wraps the given provider, dividing the values
+// of variables declaring a separator or split pattern into multiple values
+func SplitVarsProvider(vars map[string]config.Variable, provider func(string) []string) func(string) []string {
+	return func(s string) []string {
+		values := provider(s)
+		v, ok := vars[s]
+		if !ok || (v.Split == "" && v.SplitPattern == "") {
+			return values
+		}
+
+		var result []string
+		for _, value := range values {
+			result = append(result, v.SplitValue(value)...)
+		}
+
+		return result
+	}
+}
+
+// VariableError describes a request value rejected by the declaration
+// of a variable. The value itself is omitted, as it might be a secret.
+type VariableError struct {
+	Variable string
+	Err      error
+}
+
+// Error implements the error interface
+func (e *VariableError) Error() string {
+	return fmt.Sprintf("invalid value for variable %q: %s", e.Variable, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *VariableError) Unwrap() error {
+	return e.Err
+}
+
+// CheckVariables validates the values yielded by the provider
+// against the type, pattern and allowed values of the declared variables
+func CheckVariables(vars map[string]config.Variable, provider func(string) []string) error {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := vars[k]
+		for _, value := range provider(k) {
+			if value == "" {
+				// empty values are dropped
+				continue
+			}
+
+			if err := v.Check(value); err != nil {
+				return &VariableError{Variable: k, Err: err}
+			}
+		}
+	}
+
+	return nil
+}
+
 // NewLazyPluginBuilderContext creates a new plugin builder context. the variables are
 // copied as is to match the type signature of the internal struct member.
-func NewLazyPluginBuilderContext(vars map[string]config.LazyArray, env map[string]string) *PluginBuilderContext {
+func NewLazyPluginBuilderContext(vars map[string]config.Variable, env map[string]string) *PluginBuilderContext {
 	vs := make(map[string][]string, len(vars))
 	for k, v := range vars {
-		vs[k] = []string(v)
+		vs[k] = []string(v.Value)
 	}
 
 	return NewPluginBuilderContext(vs, env)
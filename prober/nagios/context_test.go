@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"gotest.tools/v3/assert"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
 )
 
 func envProviderMock(s string) string {
@@ -131,3 +133,80 @@ func TestPluginBuilderContextVisitVariables(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckVariables(t *testing.T) {
+	vars := map[string]config.Variable{
+		"host": config.Variable{Type: config.VariableTypeHost},
+		"port": config.Variable{Type: config.VariableTypePort, Value: config.LazyArray{"80"}},
+	}
+
+	type testCase struct {
+		have      map[string][]string
+		wantError string
+	}
+
+	testCases := map[string]testCase{
+		"defaults": testCase{},
+		"valid": testCase{
+			have: map[string][]string{"host": []string{"example.com"}, "port": []string{"8080", ""}},
+		},
+		"undeclared": testCase{
+			have: map[string][]string{"target": []string{"--help"}},
+		},
+		"invalid": testCase{
+			have:      map[string][]string{"host": []string{"example.com"}, "port": []string{"80", "-p"}},
+			wantError: `invalid value for variable "port": values starting with a dash are not allowed`,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			err := CheckVariables(vars, MapVarsProvider(tc.have))
+
+			if tc.wantError != "" {
+				assert.Error(t, err, tc.wantError)
+				return
+			}
+
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestOverridableVarsProvider(t *testing.T) {
+	fixed := false
+	vars := map[string]config.Variable{
+		"host":    config.Variable{},
+		"keyfile": config.Variable{Overridable: &fixed},
+	}
+	query := map[string][]string{
+		"host":    []string{"example.com"},
+		"keyfile": []string{"/etc/shadow"},
+		"other":   []string{"value"},
+	}
+
+	subject := OverridableVarsProvider(vars, MapVarsProvider(query))
+
+	assert.DeepEqual(t, []string{"example.com"}, subject("host"))
+	assert.Assert(t, subject("keyfile") == nil)
+	assert.DeepEqual(t, []string{"value"}, subject("other"))
+}
+
+func TestSplitVarsProvider(t *testing.T) {
+	vars := map[string]config.Variable{
+		"expect": config.Variable{Split: ","},
+		"uri":    config.Variable{},
+	}
+	query := map[string][]string{
+		"expect": []string{"200,301", "302"},
+		"uri":    []string{"/a,b"},
+	}
+
+	subject := SplitVarsProvider(vars, MapVarsProvider(query))
+
+	assert.DeepEqual(t, []string{"200", "301", "302"}, subject("expect"))
+	assert.DeepEqual(t, []string{"/a,b"}, subject("uri"))
+
+	ctx := NewPluginBuilderContext(map[string][]string{"expect": []string{"200"}}, nil).VisitVariables(subject)
+	assert.DeepEqual(t, []string{"200", "301", "302"}, ctx.Vars["expect"])
+}
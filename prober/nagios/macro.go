@@ -0,0 +1,71 @@
+package nagios
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+)
+
+// MacroToken marks the beginning and end of Icinga style runtime macros
+const MacroToken = config.MacroToken
+
+// MacroArrayDelimiter joins array values of macros
+// which are embedded into other text
+const MacroArrayDelimiter = ";"
+
+// HasMacros reports whether the given string contains runtime macros ($name$)
+// or the escape sequence for a literal dollar sign ($$)
+func HasMacros(s string) bool {
+	return strings.Contains(s, MacroToken+MacroToken) || len(config.SplitMacros(s)) > 1
+}
+
+// ExpandMacros resolves runtime macros ($name$) in the given string using the
+// provided variables. A string consisting of a single macro yields every value
+// of the variable, whereas embedded macros join multiple values using the
+// MacroArrayDelimiter. The result is nil if any of the referenced variables
+// has no value. $$ is replaced with a literal dollar sign.
+func ExpandMacros(s string, vars map[string][]string) []string {
+	parts := config.SplitMacros(s)
+	if len(parts) == 3 && parts[0] == "" && parts[2] == "" {
+		return vars[config.MacroVariable(parts[1])]
+	}
+
+	var result strings.Builder
+	for i, part := range parts {
+		if i%2 == 0 {
+			result.WriteString(part)
+			continue
+		}
+
+		values := vars[config.MacroVariable(part)]
+		if len(values) == 0 {
+			return nil
+		}
+
+		result.WriteString(strings.Join(values, MacroArrayDelimiter))
+	}
+
+	return []string{result.String()}
+}
+
+// ExpandMacrosBool resolves the runtime macros in the given string and
+// converts the result into a boolean. Unresolved macros and empty values
+// are false, numbers are true unless they are zero.
+func ExpandMacrosBool(s string, vars map[string][]string) (bool, error) {
+	values := ExpandMacros(s, vars)
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	v := strings.TrimSpace(strings.Join(values, MacroArrayDelimiter))
+	if v == "" {
+		return false, nil
+	}
+
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f != 0, nil
+	}
+
+	return strconv.ParseBool(v)
+}
@@ -0,0 +1,135 @@
+package nagios
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+var macroVars = map[string][]string{
+	"address": []string{"localhost"},
+	"vhosts":  []string{"a.example.com", "b.example.com"},
+	"ssl":     []string{"true"},
+	"port":    []string{"0"},
+	"empty":   []string{""},
+}
+
+func TestHasMacros(t *testing.T) {
+	testCases := map[string]bool{
+		"":                 false,
+		"plain":            false,
+		"$address$":        true,
+		"http://$address$": true,
+		"$$":               true,
+		"$5 and $10":       false,
+		"$unterminated":    false,
+		"{{ .Vars.x }}":    false,
+	}
+
+	for have, want := range testCases {
+		t.Run(have, func(t *testing.T) {
+			assert.Equal(t, want, HasMacros(have))
+		})
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	type testCase struct {
+		have string
+		want []string
+	}
+
+	testCases := map[string]testCase{
+		"plain": testCase{
+			have: "plain",
+			want: []string{"plain"},
+		},
+		"single": testCase{
+			have: "$address$",
+			want: []string{"localhost"},
+		},
+		"array": testCase{
+			have: "$vhosts$",
+			want: []string{"a.example.com", "b.example.com"},
+		},
+		"prefixed": testCase{
+			have: "$host.vars.address$",
+			want: []string{"localhost"},
+		},
+		"embedded": testCase{
+			have: "https://$address$:$port$/",
+			want: []string{"https://localhost:0/"},
+		},
+		"embedded array": testCase{
+			have: "hosts=$vhosts$",
+			want: []string{"hosts=a.example.com;b.example.com"},
+		},
+		"escaped": testCase{
+			have: "$$HOME/$address$",
+			want: []string{"$HOME/localhost"},
+		},
+		"no macro name": testCase{
+			have: "$5 and $address$",
+			want: []string{"$5 and localhost"},
+		},
+		"missing": testCase{
+			have: "$missing$",
+		},
+		"embedded missing": testCase{
+			have: "http://$missing$/",
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got := ExpandMacros(tc.have, macroVars)
+
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestExpandMacrosBool(t *testing.T) {
+	type testCase struct {
+		have      string
+		want      bool
+		wantError bool
+	}
+
+	testCases := map[string]testCase{
+		"true": testCase{
+			have: "$ssl$",
+			want: true,
+		},
+		"zero": testCase{
+			have: "$port$",
+			want: false,
+		},
+		"empty": testCase{
+			have: "$empty$",
+			want: false,
+		},
+		"missing": testCase{
+			have: "$missing$",
+			want: false,
+		},
+		"garbage": testCase{
+			have:      "$address$",
+			wantError: true,
+		},
+	}
+
+	for ctx, tc := range testCases {
+		t.Run(ctx, func(t *testing.T) {
+			got, err := ExpandMacrosBool(tc.have, macroVars)
+
+			if tc.wantError {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/config"
+	monitoring "github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/nagios"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/prober/nagios"
+	"github.com/UiP9AV6Y/prometheus-nagios-plugin-exporter/template"
+)
+
+var errNRPEArgumentsDisabled = errors.New("Command arguments are disabled")
+
+type NRPEHandler struct {
+	cache          *template.TemplateCache
+	rejected       *prometheus.CounterVec
+	logger         log.Logger
+	logLevel       level.Option
+	allowArguments bool
+	timeout        time.Duration
+}
+
+func NewNRPEHandler(cache *template.TemplateCache, rejected *prometheus.CounterVec, logger log.Logger, logLevel level.Option, allowArguments bool, timeout time.Duration) *NRPEHandler {
+	result := &NRPEHandler{
+		cache:          cache,
+		rejected:       rejected,
+		logger:         logger,
+		logLevel:       logLevel,
+		allowArguments: allowArguments,
+		timeout:        timeout,
+	}
+
+	return result
+}
+
+// ServeNRPE runs the module stored in the context. The positional arguments
+// are mapped to the variables declared in the module NRPE arguments.
+func (h *NRPEHandler) ServeNRPE(ctx context.Context, command string, arguments []string) (*monitoring.PluginResult, error) {
+	moduleName, module, ok := config.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("Command '%s' not defined", command)
+	}
+
+	vars, err := h.mapArguments(module, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	query := nagios.SplitVarsProvider(module.Variables, nagios.OverridableVarsProvider(module.Variables, nagios.MapVarsProvider(vars)))
+	if err := nagios.CheckVariables(module.Variables, query); err != nil {
+		level.Debug(h.logger).Log("msg", "Rejected NRPE query", "module", moduleName, "err", err)
+		h.rejected.WithLabelValues(moduleName, RejectInvalidVariable).Inc()
+		return nil, err
+	}
+
+	data := nagios.NewLazyPluginBuilderContext(module.Variables, module.Environment).VisitVariables(query).VisitEnvironment(os.Getenv)
+
+	builder := nagios.NewPluginBuilder(h.cache)
+	prober, err := builder.Build(module, data)
+	var missing *nagios.RequiredArgumentError
+	if errors.As(err, &missing) {
+		level.Debug(h.logger).Log("msg", "Rejected NRPE query", "module", moduleName, "err", err)
+		h.rejected.WithLabelValues(moduleName, RejectMissingArgument).Inc()
+		return nil, err
+	} else if err != nil {
+		level.Error(h.logger).Log("msg", "Unable to create module probe", "module", moduleName, "err", err)
+		return nil, fmt.Errorf("Unable to create module probe %q", moduleName)
+	}
+
+	timeoutSeconds := getTimeout(h.timeout.Seconds(), time.Duration(module.Timeout))
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds*float64(time.Second)))
+	defer cancel()
+
+	logger := level.NewFilter(log.With(h.logger, "module", moduleName), h.logLevel)
+	level.Info(logger).Log("msg", "Beginning NRPE probe", "command", prober.String(), "timeout_seconds", timeoutSeconds)
+
+	start := time.Now()
+	output, err := prober.Run(ctx)
+	duration := time.Since(start).Seconds()
+
+	if err != nil {
+		level.Error(logger).Log("msg", "Probe execution failed", "duration_seconds", duration, "err", err)
+		return nil, fmt.Errorf("Unable to execute command '%s'", command)
+	}
+
+	level.Info(logger).Log("msg", "Probe succeeded", "duration_seconds", duration, "nagios_result", output.Status)
+
+	return output, nil
+}
+
+func (h *NRPEHandler) mapArguments(module *config.Module, arguments []string) (map[string][]string, error) {
+	vars := make(map[string][]string, len(arguments))
+	if len(arguments) == 0 {
+		return vars, nil
+	}
+
+	if !h.allowArguments {
+		return nil, errNRPEArgumentsDisabled
+	}
+
+	if len(arguments) > len(module.NRPEArguments) {
+		return nil, fmt.Errorf("Command accepts at most %d arguments", len(module.NRPEArguments))
+	}
+
+	for i, a := range arguments {
+		vars[module.NRPEArguments[i]] = []string{a}
+	}
+
+	return vars, nil
+}
@@ -83,6 +83,18 @@ func (c *TemplateCache) RenderString(i, s string, ctx interface{}) (string, erro
 	return result.String(), nil
 }
 
+// Parse compiles the given template without executing it. Unlike
+// RenderString, syntax errors and unknown functions are reported.
+func (c *TemplateCache) Parse(i, s string) error {
+	if s == "" || strings.Index(s, TemplateToken) == -1 {
+		return nil
+	}
+
+	_, err := c.get(i, s)
+
+	return err
+}
+
 func (c *TemplateCache) RenderBool(i, b string, ctx interface{}) (bool, error) {
 	s, err := c.RenderString(i, b, ctx)
 	if err != nil {